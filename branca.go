@@ -0,0 +1,228 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	brancaVersion   = 0xBA
+	brancaHeaderLen = 1 + 4 + chacha20poly1305.NonceSizeX
+)
+
+var (
+	ErrBrancaVersion = errors.New("securecookie: unknown branca version")
+	ErrBase62        = errors.New("securecookie: invalid base62 value")
+)
+
+// Branca encodes and decodes values as Branca tokens.
+//
+// The format is described at https://github.com/tuupola/branca-spec. Tokens
+// carry opaque bytes, so values must be a []byte, a string or implement
+// Coder. The cookie name is not part of the token.
+type Branca struct {
+	aead      cipher.AEAD
+	maxLength int
+	maxAge    int64
+	minAge    int64
+	err       error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewBranca returns a new Branca codec.
+//
+// key is the 32 byte XChaCha20-Poly1305 key.
+func NewBranca(key []byte) *Branca {
+	b := &Branca{
+		maxAge:    86400 * 30,
+		maxLength: 4096,
+	}
+	b.aead, b.err = chacha20poly1305.NewX(key)
+	return b
+}
+
+// MaxLength restricts the maximum length, in bytes, for the token.
+//
+// Default is 4096.
+func (b *Branca) MaxLength(value int) *Branca {
+	b.maxLength = value
+	return b
+}
+
+// MaxAge restricts the maximum age, in seconds, for the token. It plays the
+// role of the ttl argument in other implementations.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (b *Branca) MaxAge(value int) *Branca {
+	b.maxAge = int64(value)
+	return b
+}
+
+// MinAge restricts the minimum age, in seconds, for the token.
+//
+// Default is 0 (no restriction).
+func (b *Branca) MinAge(value int) *Branca {
+	b.minAge = int64(value)
+	return b
+}
+
+// Encode encodes a value as a Branca token.
+//
+// The name argument is ignored.
+func (b *Branca) Encode(name string, value interface{}) (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	payload, err := marshalBytes(value)
+	if err != nil {
+		return "", err
+	}
+	nonce := GenerateRandomKey(chacha20poly1305.NonceSizeX)
+	if nonce == nil {
+		return "", errors.New("securecookie: failed to generate random nonce")
+	}
+	out := base62Encode(b.seal(payload, nonce, now(b.timeFunc)))
+	if b.maxLength != 0 && len(out) > b.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a Branca token.
+//
+// The name argument is ignored. The dst argument must be a *[]byte,
+// a *string or implement Coder.
+func (b *Branca) Decode(name, value string, dst interface{}) error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.maxLength != 0 && len(value) > b.maxLength {
+		return ErrTooLong
+	}
+	token, err := base62Decode(value)
+	if err != nil {
+		return err
+	}
+	payload, err := b.open(token, now(b.timeFunc))
+	if err != nil {
+		return err
+	}
+	return unmarshalBytes(payload, dst)
+}
+
+// seal builds a token for the given payload, nonce and timestamp.
+func (b *Branca) seal(payload, nonce []byte, ts int64) []byte {
+	header := make([]byte, brancaHeaderLen, brancaHeaderLen+len(payload)+b.aead.Overhead())
+	header[0] = brancaVersion
+	binary.BigEndian.PutUint32(header[1:5], uint32(ts))
+	copy(header[5:], nonce)
+	return b.aead.Seal(header, nonce, payload, header)
+}
+
+// open verifies a token and returns its payload.
+func (b *Branca) open(token []byte, t2 int64) ([]byte, error) {
+	if len(token) < brancaHeaderLen+b.aead.Overhead() {
+		return nil, ErrMacInvalid
+	}
+	if token[0] != brancaVersion {
+		return nil, ErrBrancaVersion
+	}
+	header := token[:brancaHeaderLen]
+	payload, err := b.aead.Open(nil, header[5:], token[brancaHeaderLen:], header)
+	if err != nil {
+		return nil, ErrMacInvalid
+	}
+	t1 := int64(binary.BigEndian.Uint32(header[1:5]))
+	if err = checkAge(t1, t2, b.minAge, b.maxAge); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Base62 -----------------------------------------------------------------------
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// base62Encode encodes a value using base62. Leading zero bytes are encoded
+// as leading '0' characters, as done by the base-x family of encoders.
+func base62Encode(value []byte) string {
+	zeros := 0
+	for zeros < len(value) && value[zeros] == 0 {
+		zeros++
+	}
+	// log(256) / log(62) < 1.35
+	digits := make([]byte, 0, len(value)*135/100+1)
+	for _, c := range value[zeros:] {
+		carry := int(c)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 62)
+			carry /= 62
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%62))
+			carry /= 62
+		}
+	}
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = base62Alphabet[0]
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base62Alphabet[d]
+	}
+	return string(out)
+}
+
+// base62Decode decodes a base62 value.
+func base62Decode(value string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(value) && value[zeros] == base62Alphabet[0] {
+		zeros++
+	}
+	// log(62) / log(256) < 0.75
+	bytes := make([]byte, 0, len(value)*3/4+1)
+	for i := zeros; i < len(value); i++ {
+		carry := base62Index(value[i])
+		if carry < 0 {
+			return nil, ErrBase62
+		}
+		for j := range bytes {
+			carry += int(bytes[j]) * 62
+			bytes[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			bytes = append(bytes, byte(carry))
+			carry >>= 8
+		}
+	}
+	out := make([]byte, zeros+len(bytes))
+	for i, c := range bytes {
+		out[len(out)-1-i] = c
+	}
+	return out, nil
+}
+
+// base62Index returns the value of a base62 character, or -1.
+func base62Index(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 36
+	}
+	return -1
+}
@@ -0,0 +1,126 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Vectors from https://github.com/tuupola/branca-spec.
+var brancaVectors = []struct {
+	Token string
+	Key   string
+	Nonce string
+	Time  int64
+	Msg   string
+}{
+	{
+		"875GH233T7IYrxtgXxlQBYiFobZMQdHAT51vChKsAIYCFxZtL1evV54vYqLyZtQ0ekPHt8kJHQp0a",
+		"73757065727365637265746b6579796f7573686f756c646e6f74636f6d6d6974",
+		"0102030405060708090a0b0c0102030405060708090a0b0c",
+		123206400,
+		"Hello world!",
+	},
+	{
+		"89i7YCwu5tWAJNHUDdmIqhzOi5hVHOd4afjZcGMcVmM4enl4yeLiDyYv41eMkNmTX6IwYEFErCSqr",
+		"73757065727365637265746b6579796f7573686f756c646e6f74636f6d6d6974",
+		"beefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeef",
+		4294967295,
+		"Hello world!",
+	},
+	{
+		"4sfD0vPFhIif8cy4nB3BQkHeJqkOkDvinI4zIhMjYX4YXZU5WIq9ycCVjGzB5",
+		"73757065727365637265746b6579796f7573686f756c646e6f74636f6d6d6974",
+		"beefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeef",
+		0,
+		"",
+	},
+}
+
+func TestBrancaVectors(t *testing.T) {
+	for _, v := range brancaVectors {
+		key, _ := hex.DecodeString(v.Key)
+		nonce, _ := hex.DecodeString(v.Nonce)
+		b := NewBranca(key).MaxAge(0)
+		if got := base62Encode(b.seal([]byte(v.Msg), nonce, v.Time)); got != v.Token {
+			t.Errorf("Expected %v, got %v.", v.Token, got)
+		}
+		b.timeFunc = func() int64 { return v.Time }
+		var dst string
+		if err := b.Decode("", v.Token, &dst); err != nil {
+			t.Error(err)
+		} else if dst != v.Msg {
+			t.Errorf("Expected %q, got %q.", v.Msg, dst)
+		}
+	}
+}
+
+func TestBrancaInvalid(t *testing.T) {
+	v := brancaVectors[0]
+	key, _ := hex.DecodeString(v.Key)
+	nonce, _ := hex.DecodeString(v.Nonce)
+	b := NewBranca(key).MaxAge(3600)
+	token := b.seal([]byte(v.Msg), nonce, v.Time)
+	tamper := func(i int) string {
+		t := append([]byte(nil), token...)
+		t[i] ^= 1
+		return base62Encode(t)
+	}
+	tests := []struct {
+		Name  string
+		Token string
+		Now   int64
+		Err   error
+	}{
+		{"modified timestamp", tamper(2), v.Time, ErrMacInvalid},
+		{"modified tag", tamper(len(token) - 1), v.Time, ErrMacInvalid},
+		{"wrong version", base62Encode(append([]byte{0xBB}, token[1:]...)), v.Time, ErrBrancaVersion},
+		{"invalid base62", "875GH233T7IYrxtgXxlQBYiFobZMQdHAT51vChKsAIYCFxZtL1evV54vYqLyZtQ0ekPHt8kJHQp0_", v.Time, ErrBase62},
+		{"expired", v.Token, v.Time + 3601, ErrExpired},
+	}
+	for _, test := range tests {
+		b.timeFunc = func() int64 { return test.Now }
+		var dst []byte
+		if err := b.Decode("", test.Token, &dst); err != test.Err {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Err, err)
+		}
+	}
+}
+
+func TestBase62(t *testing.T) {
+	tests := [][]byte{
+		{},
+		{0},
+		{0, 0, 1},
+		{255, 254, 253},
+		[]byte("Hello world!"),
+	}
+	for _, value := range tests {
+		decoded, err := base62Decode(base62Encode(value))
+		if err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(decoded, value) {
+			t.Errorf("Expected %v, got %v.", value, decoded)
+		}
+	}
+}
+
+func TestBrancaRoundtrip(t *testing.T) {
+	b := NewBranca(GenerateRandomKey(32))
+	encoded, err := b.Encode("", []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst []byte
+	if err = DecodeMulti("", encoded, &dst, NewBranca(GenerateRandomKey(32)), b); err != nil {
+		t.Fatal(err)
+	}
+	if string(dst) != "payload" {
+		t.Errorf("Expected %q, got %q.", "payload", dst)
+	}
+}
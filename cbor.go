@@ -0,0 +1,785 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCBORInvalid = errors.New("securecookie: invalid cbor data")
+	ErrCBORDepth   = errors.New("securecookie: cbor value exceeds the maximum depth")
+)
+
+// CBOR major types.
+const (
+	cborUint = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// CBORSerializer encodes cookie values using CBOR (RFC 8949), with the Core
+// Deterministic Encoding of section 4.2.1: integers, lengths and floats use
+// their shortest form, lengths are always definite and map keys are sorted
+// by their encoding. Identical values always produce identical bytes.
+//
+// Structs are encoded as maps keyed by field name. The name can be changed
+// with a `cbor:"name"` field tag, and the "omitempty" option and the "-"
+// name work as they do for encoding/json. With the "keyasint" option, as in
+// `cbor:"1,keyasint"`, the field is keyed by the integer name instead,
+// which keeps values compact. Embedded structs are inlined, except
+// time.Time, which is a field named "Time". Byte slices are encoded as byte
+// strings. time.Time values are encoded as epoch-based
+// timestamps (tag 1) when they have no fractional seconds, and as RFC 3339
+// timestamps (tag 0) otherwise.
+//
+// Values decoded into an interface{} are nil, bool, int64, uint64, float64,
+// string, []byte, time.Time, []interface{}, or map[string]interface{}
+// (map[interface{}]interface{} for maps with keys that are not strings).
+// Values of unknown tags are decoded as if they were not tagged. Values of
+// indefinite length are rejected.
+//
+// It is safe for concurrent use and needs no registration of types.
+type CBORSerializer struct {
+	maxLength int
+	maxDepth  int
+}
+
+// NewCBORSerializer returns a new CBORSerializer.
+func NewCBORSerializer() *CBORSerializer {
+	return &CBORSerializer{
+		maxLength: 4096,
+		maxDepth:  32,
+	}
+}
+
+// MaxLength restricts the maximum length, in bytes, of values accepted by
+// Deserialize. Independently of it, the lengths announced by values are
+// checked against the length of the input before allocating memory.
+//
+// Default is 4096. Set it to 0 for no restriction.
+func (c *CBORSerializer) MaxLength(value int) *CBORSerializer {
+	c.maxLength = value
+	return c
+}
+
+// MaxDepth restricts the nesting depth of arrays, maps and tags accepted by
+// Deserialize.
+//
+// Default is 32. Set it to 0 for no restriction.
+func (c *CBORSerializer) MaxDepth(value int) *CBORSerializer {
+	c.maxDepth = value
+	return c
+}
+
+// Serialize encodes a value using deterministically encoded CBOR.
+func (c *CBORSerializer) Serialize(src interface{}) ([]byte, error) {
+	e := &cborEncoder{}
+	if err := e.encode(reflect.ValueOf(src)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Deserialize decodes a value using CBOR. The dst argument must be a
+// pointer.
+func (c *CBORSerializer) Deserialize(src []byte, dst interface{}) error {
+	if c.maxLength != 0 && len(src) > c.maxLength {
+		return ErrTooLong
+	}
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("securecookie: cbor destination must be a non-nil pointer")
+	}
+	d := &cborDecoder{buf: src, maxDepth: c.maxDepth}
+	if err := d.decode(v.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return ErrCBORInvalid
+	}
+	return nil
+}
+
+// Fields ---------------------------------------------------------------------
+
+// cborField describes how a struct field is encoded.
+type cborField struct {
+	name      string
+	key       []byte // deterministic encoding of the key
+	keyAsInt  bool
+	keyInt    int64
+	index     []int
+	omitEmpty bool
+}
+
+var cborFieldCache sync.Map // map[reflect.Type][]cborField
+
+// cborFields returns the encoded fields of a struct type, sorted by the
+// encoding of their keys.
+func cborFields(t reflect.Type) ([]cborField, error) {
+	if f, ok := cborFieldCache.Load(t); ok {
+		return f.([]cborField), nil
+	}
+	var fields []cborField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("cbor")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct && !hasOwnEncoding(sf.Type) {
+			embedded, err := cborFields(sf.Type)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range embedded {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := cborField{name: name, index: []int{i}}
+		e := &cborEncoder{}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "keyasint":
+				n, err := strconv.ParseInt(name, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("securecookie: cbor key of field %s is not an integer", sf.Name)
+				}
+				f.keyAsInt, f.keyInt = true, n
+			}
+		}
+		if f.keyAsInt {
+			e.writeInt(f.keyInt)
+		} else {
+			e.writeHead(cborText, uint64(len(name)))
+			e.buf = append(e.buf, name...)
+		}
+		f.key = e.buf
+		fields = append(fields, f)
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return bytes.Compare(fields[i].key, fields[j].key) < 0 })
+	cborFieldCache.Store(t, fields)
+	return fields, nil
+}
+
+// Encoding -------------------------------------------------------------------
+
+// cborEncoder appends deterministically encoded CBOR values to a buffer.
+type cborEncoder struct {
+	buf []byte
+}
+
+func (e *cborEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xf6)
+		return nil
+	}
+	if v.Type() == timeType {
+		e.writeTime(v.Interface().(time.Time))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xf5)
+		} else {
+			e.buf = append(e.buf, 0xf4)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeHead(cborUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.writeFloat(v.Float())
+	case reflect.String:
+		e.writeHead(cborText, uint64(v.Len()))
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeHead(cborBytes, uint64(v.Len()))
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeHead(cborBytes, uint64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xf6)
+			return nil
+		}
+		// Encode the entries separately, to sort them by their keys.
+		type entry struct{ key, value []byte }
+		entries := make([]entry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := &cborEncoder{}
+			if err := k.encode(iter.Key()); err != nil {
+				return err
+			}
+			val := &cborEncoder{}
+			if err := val.encode(iter.Value()); err != nil {
+				return err
+			}
+			entries = append(entries, entry{k.buf, val.buf})
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+		e.writeHead(cborMap, uint64(len(entries)))
+		for _, entry := range entries {
+			e.buf = append(e.buf, entry.key...)
+			e.buf = append(e.buf, entry.value...)
+		}
+	case reflect.Struct:
+		fields, err := cborFields(v.Type())
+		if err != nil {
+			return err
+		}
+		values := make([]reflect.Value, len(fields))
+		n := 0
+		for i, f := range fields {
+			values[i] = v.FieldByIndex(f.index)
+			if !f.omitEmpty || !isEmptyValue(values[i]) {
+				n++
+			}
+		}
+		e.writeHead(cborMap, uint64(n))
+		for i, f := range fields {
+			if f.omitEmpty && isEmptyValue(values[i]) {
+				continue
+			}
+			e.buf = append(e.buf, f.key...)
+			if err = e.encode(values[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("securecookie: cbor cannot encode %v", v.Type())
+	}
+	return nil
+}
+
+func (e *cborEncoder) encodeArray(v reflect.Value) error {
+	e.writeHead(cborArray, uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHead writes the initial bytes of a data item, with the argument in
+// its shortest form.
+func (e *cborEncoder) writeHead(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, major|25)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, major|26)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, major|27)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func (e *cborEncoder) writeInt(n int64) {
+	if n >= 0 {
+		e.writeHead(cborUint, uint64(n))
+	} else {
+		e.writeHead(cborNegInt, uint64(-1-n))
+	}
+}
+
+// writeFloat writes a float in the shortest form that preserves its value.
+func (e *cborEncoder) writeFloat(f float64) {
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		if h, ok := float16Bits(f32); ok {
+			e.buf = append(e.buf, 0xf9)
+			e.buf = binary.BigEndian.AppendUint16(e.buf, h)
+			return
+		}
+		e.buf = append(e.buf, 0xfa)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(f32))
+		return
+	}
+	e.buf = append(e.buf, 0xfb)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+}
+
+func (e *cborEncoder) writeTime(t time.Time) {
+	if t.Nanosecond() == 0 {
+		e.writeHead(cborTag, 1)
+		e.writeInt(t.Unix())
+		return
+	}
+	s := t.UTC().Format(time.RFC3339Nano)
+	e.writeHead(cborTag, 0)
+	e.writeHead(cborText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// float16Bits returns the half precision representation of f, if it can
+// be represented exactly. NaN is always represented as 0x7e00.
+func float16Bits(f float32) (uint16, bool) {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xff
+	mant := b & 0x7fffff
+	switch {
+	case exp == 0xff && mant != 0:
+		return 0x7e00, true
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		return 0, false
+	}
+	e := exp - 127 + 15
+	switch {
+	case e >= 31:
+		return 0, false
+	case e > 0:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e)<<10 | uint16(mant>>13), true
+	}
+	// Subnormal half precision values are multiples of 2^-24.
+	full := mant | 0x800000
+	shift := uint(126 - exp)
+	if shift >= 24 || full&(1<<shift-1) != 0 {
+		return 0, false
+	}
+	return sign | uint16(full>>shift), true
+}
+
+// float16Value returns the value of a half precision float.
+func float16Value(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant != 0 {
+			return math.NaN()
+		}
+		f = math.Inf(1)
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// Decoding -------------------------------------------------------------------
+
+// cborDecoder reads CBOR values from a buffer.
+type cborDecoder struct {
+	buf      []byte
+	pos      int
+	depth    int
+	maxDepth int
+}
+
+// read returns the next n bytes of the buffer.
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, ErrCBORInvalid
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// readHead reads the initial bytes of a data item. For major type 7, ai is
+// the additional information and n the raw bits of floats.
+func (d *cborDecoder) readHead() (major, ai byte, n uint64, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, ai = b[0]>>5, b[0]&0x1f
+	switch {
+	case ai < 24:
+		return major, ai, uint64(ai), nil
+	case ai <= 27:
+		if b, err = d.read(1 << (ai - 24)); err != nil {
+			return 0, 0, 0, err
+		}
+		return major, ai, beUint(b), nil
+	}
+	// Indefinite lengths and reserved values.
+	return 0, 0, 0, ErrCBORInvalid
+}
+
+// enter increments the nesting depth and checks that n elements of at least
+// one byte each fit in the rest of the buffer.
+func (d *cborDecoder) enter(n uint64) error {
+	if d.depth++; d.maxDepth != 0 && d.depth > d.maxDepth {
+		return ErrCBORDepth
+	}
+	if n > uint64(len(d.buf)-d.pos) {
+		return ErrCBORInvalid
+	}
+	return nil
+}
+
+// decodeValue decodes any value into the types documented for
+// CBORSerializer.
+func (d *cborDecoder) decodeValue() (interface{}, error) {
+	start := d.pos
+	major, ai, n, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("securecookie: cbor integer -1-%d overflows int64", n)
+		}
+		return -1 - int64(n), nil
+	case cborBytes:
+		b, err := d.read(n)
+		return append([]byte(nil), b...), err
+	case cborText:
+		b, err := d.read(n)
+		return string(b), err
+	case cborArray:
+		if err = d.enter(n); err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = d.decodeValue(); err != nil {
+				return nil, err
+			}
+		}
+		d.depth--
+		return a, nil
+	case cborMap:
+		return d.decodeMap(n)
+	case cborTag:
+		if n == 0 || n == 1 {
+			d.pos = start
+			return d.readTime()
+		}
+		if err = d.enter(1); err != nil {
+			return nil, err
+		}
+		x, err := d.decodeValue()
+		d.depth--
+		return x, err
+	}
+	switch ai {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return float16Value(uint16(n)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	}
+	return nil, ErrCBORInvalid
+}
+
+func (d *cborDecoder) decodeMap(n uint64) (interface{}, error) {
+	if n > math.MaxInt32 {
+		return nil, ErrCBORInvalid
+	}
+	if err := d.enter(2 * n); err != nil {
+		return nil, err
+	}
+	keys, values := make([]interface{}, n), make([]interface{}, n)
+	stringKeys := true
+	for i := range keys {
+		var err error
+		if keys[i], err = d.decodeValue(); err != nil {
+			return nil, err
+		}
+		if values[i], err = d.decodeValue(); err != nil {
+			return nil, err
+		}
+		if _, ok := keys[i].(string); !ok {
+			stringKeys = false
+		}
+	}
+	d.depth--
+	if stringKeys {
+		m := make(map[string]interface{}, n)
+		for i, k := range keys {
+			m[k.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, ErrCBORInvalid
+		}
+		m[k] = values[i]
+	}
+	return m, nil
+}
+
+// readTime reads a standard (tag 0) or epoch-based (tag 1) timestamp.
+func (d *cborDecoder) readTime() (time.Time, error) {
+	major, _, tag, err := d.readHead()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if major != cborTag || tag > 1 {
+		return time.Time{}, ErrCBORInvalid
+	}
+	if err = d.enter(1); err != nil {
+		return time.Time{}, err
+	}
+	x, err := d.decodeValue()
+	if err != nil {
+		return time.Time{}, err
+	}
+	d.depth--
+	switch v := x.(type) {
+	case string:
+		if tag == 0 {
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	case int64:
+		if tag == 1 {
+			return time.Unix(v, 0), nil
+		}
+	case float64:
+		if tag == 1 && !math.IsNaN(v) && !math.IsInf(v, 0) {
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)), nil
+		}
+	}
+	return time.Time{}, ErrCBORInvalid
+}
+
+// decode decodes a value into v, which must be settable.
+func (d *cborDecoder) decode(v reflect.Value) error {
+	if d.pos < len(d.buf) && (d.buf[d.pos] == 0xf6 || d.buf[d.pos] == 0xf7) {
+		d.pos++
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := d.readTime()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			break
+		}
+		x, err := d.decodeValue()
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			major, _, n, err := d.readHead()
+			if err != nil {
+				return err
+			}
+			if major != cborBytes && major != cborText {
+				return fmt.Errorf("securecookie: cbor cannot decode major type %d into %v", major, v.Type())
+			}
+			b, err := d.read(n)
+			if err != nil {
+				return err
+			}
+			if v.Kind() == reflect.Slice {
+				v.SetBytes(append([]byte(nil), b...))
+			} else if len(b) != v.Len() {
+				return fmt.Errorf("securecookie: cbor cannot decode %d bytes into %v", len(b), v.Type())
+			} else {
+				reflect.Copy(v, reflect.ValueOf(b))
+			}
+			return nil
+		}
+		major, _, n, err := d.readHead()
+		if err != nil {
+			return err
+		}
+		if major != cborArray {
+			return fmt.Errorf("securecookie: cbor cannot decode major type %d into %v", major, v.Type())
+		}
+		if err = d.enter(n); err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), int(n), int(n)))
+		} else if int(n) != v.Len() {
+			return fmt.Errorf("securecookie: cbor cannot decode %d elements into %v", n, v.Type())
+		}
+		for i := 0; i < int(n); i++ {
+			if err = d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		d.depth--
+		return nil
+	case reflect.Map:
+		n, err := d.readMapHead(v.Type())
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), int(n)))
+		}
+		for i := 0; i < int(n); i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err = d.decode(key); err != nil {
+				return err
+			}
+			// Interface keys may hold values that cannot be map keys.
+			if !key.Comparable() {
+				return ErrCBORInvalid
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = d.decode(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+		d.depth--
+		return nil
+	case reflect.Struct:
+		n, err := d.readMapHead(v.Type())
+		if err != nil {
+			return err
+		}
+		fields, err := cborFields(v.Type())
+		if err != nil {
+			return err
+		}
+		for i := 0; i < int(n); i++ {
+			key, err := d.decodeValue()
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, f := range fields {
+				if f.keyAsInt && key == f.keyInt || !f.keyAsInt && key == f.name {
+					if err = d.decode(v.FieldByIndex(f.index)); err != nil {
+						return err
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				// Skip values of unknown fields.
+				if _, err = d.decodeValue(); err != nil {
+					return err
+				}
+			}
+		}
+		d.depth--
+		return nil
+	}
+	x, err := d.decodeValue()
+	if err != nil {
+		return err
+	}
+	return setScalar(v, x)
+}
+
+// readMapHead reads the head of a map to be decoded into a value of type t.
+func (d *cborDecoder) readMapHead(t reflect.Type) (uint64, error) {
+	major, _, n, err := d.readHead()
+	if err != nil {
+		return 0, err
+	}
+	if major != cborMap {
+		return 0, fmt.Errorf("securecookie: cbor cannot decode major type %d into %v", major, t)
+	}
+	if n > math.MaxInt32 {
+		return 0, ErrCBORInvalid
+	}
+	return n, d.enter(2 * n)
+}
@@ -0,0 +1,213 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Examples of Appendix A of RFC 8949, in their deterministic encoding.
+var cborVectors = []struct {
+	value interface{}
+	hex   string
+}{
+	{0, "00"},
+	{23, "17"},
+	{24, "1818"},
+	{100, "1864"},
+	{1000, "1903e8"},
+	{1000000, "1a000f4240"},
+	{uint64(1000000000000), "1b000000e8d4a51000"},
+	{uint64(18446744073709551615), "1bffffffffffffffff"},
+	{-1, "20"},
+	{-100, "3863"},
+	{-1000, "3903e7"},
+	{0.0, "f90000"},
+	{math.Copysign(0, -1), "f98000"},
+	{1.0, "f93c00"},
+	{1.1, "fb3ff199999999999a"},
+	{1.5, "f93e00"},
+	{65504.0, "f97bff"},
+	{100000.0, "fa47c35000"},
+	{3.4028234663852886e+38, "fa7f7fffff"},
+	{1.0e+300, "fb7e37e43c8800759c"},
+	{5.960464477539063e-8, "f90001"},
+	{0.00006103515625, "f90400"},
+	{-4.0, "f9c400"},
+	{-4.1, "fbc010666666666666"},
+	{math.Inf(1), "f97c00"},
+	{math.NaN(), "f97e00"},
+	{math.Inf(-1), "f9fc00"},
+	{false, "f4"},
+	{true, "f5"},
+	{nil, "f6"},
+	{time.Unix(1363896240, 0), "c11a514b67b0"},
+	{[]byte{}, "40"},
+	{[]byte{1, 2, 3, 4}, "4401020304"},
+	{"", "60"},
+	{"a", "6161"},
+	{"IETF", "6449455446"},
+	{"ü", "62c3bc"},
+	{[]int{}, "80"},
+	{[]int{1, 2, 3}, "83010203"},
+	{[]interface{}{1, []int{2, 3}, []int{4, 5}}, "8301820203820405"},
+	{map[int]int{}, "a0"},
+	{map[int]int{1: 2, 3: 4}, "a201020304"},
+	{map[string]interface{}{"a": 1, "b": []int{2, 3}}, "a26161016162820203"},
+	{map[string]string{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, "a56161614161626142616361436164614461656145"},
+	// Keys are sorted by their encoding, so shorter keys come first.
+	{map[interface{}]int{"aa": 1, "b": 2, 10: 3, -1: 4}, "a40a03200461620262616101"},
+}
+
+func TestCBORVectors(t *testing.T) {
+	c := NewCBORSerializer()
+	for _, test := range cborVectors {
+		b, err := c.Serialize(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(b); got != test.hex {
+			t.Errorf("%v: expected %v, got %v.", test.value, test.hex, got)
+		}
+	}
+	var dst interface{}
+	for _, test := range []struct {
+		hex   string
+		value interface{}
+	}{
+		{"f93c00", 1.0},
+		{"fa47c35000", 100000.0},
+		{"f90001", 5.960464477539063e-8},
+		{"3903e7", int64(-1000)},
+		{"1bffffffffffffffff", uint64(18446744073709551615)},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Unix(1363896240, 0)},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 500000000)},
+		{"d74401020304", []byte{1, 2, 3, 4}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+	} {
+		b, _ := hex.DecodeString(test.hex)
+		if err := c.Deserialize(b, &dst); err != nil {
+			t.Fatalf("%v: %v", test.hex, err)
+		}
+		if tm, ok := test.value.(time.Time); ok {
+			if !tm.Equal(dst.(time.Time)) {
+				t.Errorf("%v: expected %v, got %v.", test.hex, tm, dst)
+			}
+		} else if !reflect.DeepEqual(dst, test.value) {
+			t.Errorf("%v: expected %#v, got %#v.", test.hex, test.value, dst)
+		}
+	}
+}
+
+type cborSession struct {
+	ID      int       `cbor:"1,keyasint"`
+	Name    string    `cbor:"2,keyasint"`
+	Admin   bool      `cbor:"3,keyasint,omitempty"`
+	Token   []byte    `cbor:"token"`
+	Expires time.Time `cbor:"exp"`
+	Scores  map[int]float64
+	Ignored string `cbor:"-"`
+}
+
+func TestCBORSerializer(t *testing.T) {
+	s := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewCBORSerializer())
+	src := &cborSession{
+		ID:      42,
+		Name:    "gopher",
+		Token:   []byte{0, 1, 255},
+		Expires: time.Unix(1600000000, 5).UTC(),
+		Scores:  map[int]float64{1: 0.5, 2: 1.1},
+	}
+	encoded, err := s.Encode("sid", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &cborSession{}
+	if err = s.Decode("sid", encoded, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("Expected %+v, got %+v.", src, dst)
+	}
+
+	c := NewCBORSerializer()
+	b, err := c.Serialize(cborSession{ID: 1, Name: "a", Expires: time.Unix(0, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Integer keys sort before text keys; Admin is omitted.
+	expected := "a5" + "0101" + "026161" + "63657870" + "c100" + "65746f6b656e" + "f6" + "6653636f726573" + "f6"
+	if got := hex.EncodeToString(b); got != expected {
+		t.Errorf("Expected %v, got %v.", expected, got)
+	}
+}
+
+func TestCBOREmbeddedTime(t *testing.T) {
+	// An embedded time.Time is a field named after its type, not inlined.
+	type stamped struct {
+		time.Time
+		ID int `cbor:"id"`
+	}
+	c := NewCBORSerializer()
+	src := stamped{time.Unix(1600000000, 0), 42}
+	b, err := c.Serialize(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst stamped
+	if err = c.Deserialize(b, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !dst.Time.Equal(src.Time) || dst.ID != 42 {
+		t.Errorf("Expected %v, got %v.", src, dst)
+	}
+	var generic map[string]interface{}
+	if err = c.Deserialize(b, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic["Time"].(time.Time); !ok {
+		t.Errorf("Unexpected value %v", generic)
+	}
+}
+
+func TestCBORLimits(t *testing.T) {
+	c := NewCBORSerializer()
+	var dst interface{}
+	deep := append(bytes.Repeat([]byte{0x81}, 33), 0xf6)
+	if err := c.Deserialize(deep, &dst); err != ErrCBORDepth {
+		t.Errorf("Expected %v, got %v.", ErrCBORDepth, err)
+	}
+	if err := c.MaxDepth(0).Deserialize(deep, &dst); err != nil {
+		t.Error(err)
+	}
+	for _, data := range [][]byte{
+		// An array announcing more elements than the input holds.
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		// A byte string longer than the input.
+		{0x5a, 0xff, 0xff, 0xff, 0xff},
+		// Indefinite length array.
+		{0x9f, 0x01, 0xff},
+		// Trailing data.
+		{0x01, 0x02},
+	} {
+		if err := c.Deserialize(data, &dst); err != ErrCBORInvalid {
+			t.Errorf("%x: expected %v, got %v.", data, ErrCBORInvalid, err)
+		}
+	}
+	if err := c.MaxLength(2).Deserialize([]byte{0x43, 1, 2, 3}, &dst); err != ErrTooLong {
+		t.Errorf("Expected %v, got %v.", ErrTooLong, err)
+	}
+	// A map with an array key.
+	var keyed map[interface{}]interface{}
+	if err := NewCBORSerializer().Deserialize([]byte{0xa1, 0x81, 0x01, 0x01}, &keyed); err != ErrCBORInvalid {
+		t.Errorf("Expected %v, got %v.", ErrCBORInvalid, err)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// claimFormat handles the registered "exp", "nbf" and "iat" claims of token
+// payloads, which token formats store in different time formats.
+type claimFormat struct {
+	// err is returned for payloads with invalid claims.
+	err error
+	// encode returns the JSON value of a timestamp.
+	encode func(t int64) interface{}
+	// decode returns the timestamp of a JSON value.
+	decode func(b []byte) (int64, error)
+}
+
+var (
+	// jwtClaims stores claims as NumericDate values, as specified by RFC 7519.
+	jwtClaims = claimFormat{
+		err:    ErrJWTClaims,
+		encode: func(t int64) interface{} { return t },
+		decode: func(b []byte) (int64, error) {
+			var t float64
+			err := json.Unmarshal(b, &t)
+			return int64(t), err
+		},
+	}
+	// pasetoClaims stores claims as RFC 3339 dates, as specified by PASETO.
+	pasetoClaims = claimFormat{
+		err:    ErrPasetoClaims,
+		encode: func(t int64) interface{} { return time.Unix(t, 0).UTC().Format(time.RFC3339) },
+		decode: func(b []byte) (int64, error) {
+			var t time.Time
+			err := json.Unmarshal(b, &t)
+			return t.Unix(), err
+		},
+	}
+)
+
+// marshal encodes a value as a token payload, adding the "iat" and, when
+// configured, "nbf" and "exp" claims to JSON objects. Values that implement
+// Coder are encoded as is.
+func (f claimFormat) marshal(value interface{}, t, minAge, maxAge int64) ([]byte, error) {
+	if enc, ok := value.(Coder); ok {
+		return enc.Marshal()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 || b[0] != '{' {
+		return b, nil
+	}
+	var claims map[string]json.RawMessage
+	if err = json.Unmarshal(b, &claims); err != nil {
+		return nil, err
+	}
+	setClaim := func(key string, t int64) {
+		if _, ok := claims[key]; !ok {
+			claims[key], _ = json.Marshal(f.encode(t))
+		}
+	}
+	setClaim("iat", t)
+	if minAge != 0 {
+		setClaim("nbf", t+minAge)
+	}
+	if maxAge != 0 {
+		setClaim("exp", t+maxAge)
+	}
+	return json.Marshal(claims)
+}
+
+// unmarshal validates the claims of a payload and stores it in dst, which
+// is either a Coder or decoded as JSON.
+func (f claimFormat) unmarshal(payload []byte, dst interface{}, t2, minAge, maxAge int64) error {
+	dec, ok := dst.(Coder)
+	// Values encoded by a Coder are not given claims.
+	if err := f.check(payload, t2, minAge, maxAge, !ok); err != nil {
+		return err
+	}
+	if ok {
+		return dec.Unmarshal(payload)
+	}
+	return json.Unmarshal(payload, dst)
+}
+
+// check validates the "exp", "nbf" and "iat" claims of a payload at time t2,
+// and applies minAge and maxAge to "iat". Payloads that are not JSON objects
+// carry no claims. If required is set, minAge and maxAge require an "iat" or
+// "exp" claim.
+func (f claimFormat) check(payload []byte, t2, minAge, maxAge int64, required bool) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(payload), []byte("{")) || !json.Valid(payload) {
+		return nil
+	}
+	var raw struct {
+		Exp json.RawMessage `json:"exp"`
+		Nbf json.RawMessage `json:"nbf"`
+		Iat json.RawMessage `json:"iat"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return f.err
+	}
+	claims := make(map[string]int64, 3)
+	for key, b := range map[string]json.RawMessage{"exp": raw.Exp, "nbf": raw.Nbf, "iat": raw.Iat} {
+		if len(b) == 0 || string(b) == "null" {
+			continue
+		}
+		t, err := f.decode(b)
+		if err != nil {
+			return f.err
+		}
+		claims[key] = t
+	}
+	exp, hasExp := claims["exp"]
+	if hasExp && t2 >= exp {
+		return ErrExpired
+	}
+	if nbf, ok := claims["nbf"]; ok && nbf > t2 {
+		return ErrTooNew
+	}
+	iat, ok := claims["iat"]
+	if !ok {
+		if required && !hasExp && (minAge != 0 || maxAge != 0) {
+			return ErrTimeInvalid
+		}
+		return nil
+	}
+	if iat > t2 {
+		return ErrTooNew
+	}
+	return checkAge(iat, t2, minAge, maxAge)
+}
@@ -0,0 +1,46 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package example holds types to test the code generated by
+// securecookie-gen.
+package example
+
+import "time"
+
+//go:generate go run .. -type=Session,Profile
+
+// Role is the role of a user.
+type Role string
+
+// Session is a session stored in a cookie.
+type Session struct {
+	ID       string
+	UserID   uint64
+	Roles    []Role
+	Flash    map[string][]string
+	Created  time.Time
+	Expires  *time.Time
+	TTL      time.Duration
+	Score    float64
+	Admin    bool
+	Token    [16]byte
+	Data     []byte
+	Profile  Profile
+	Previous *Profile
+	Limits   map[string]int8
+	Cache    string `securecookie:"-"`
+}
+
+// Profile is the public profile of a user.
+type Profile struct {
+	Name   string
+	Age    int
+	Ratio  float32
+	Visits [2]uint16
+	Links  []struct {
+		URL    string
+		Clicks uint32
+	}
+}
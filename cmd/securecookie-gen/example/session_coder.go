@@ -0,0 +1,571 @@
+// Code generated by securecookie-gen; DO NOT EDIT.
+
+package example
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// Marshal encodes x. It implements securecookie.Coder.
+func (x *Session) Marshal() ([]byte, error) {
+	var b []byte
+	b = binary.AppendUvarint(b, uint64(len(x.ID)))
+	b = append(b, x.ID...)
+	b = binary.AppendUvarint(b, uint64(x.UserID))
+	b = binary.AppendUvarint(b, uint64(len(x.Roles)))
+	for _, v1 := range x.Roles {
+		b = binary.AppendUvarint(b, uint64(len(v1)))
+		b = append(b, string(v1)...)
+	}
+	b = binary.AppendUvarint(b, uint64(len(x.Flash)))
+	for k2, v3 := range x.Flash {
+		b = binary.AppendUvarint(b, uint64(len(k2)))
+		b = append(b, k2...)
+		b = binary.AppendUvarint(b, uint64(len(v3)))
+		for _, v4 := range v3 {
+			b = binary.AppendUvarint(b, uint64(len(v4)))
+			b = append(b, v4...)
+		}
+	}
+	{
+		t5, err := x.Created.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = binary.AppendUvarint(b, uint64(len(t5)))
+		b = append(b, t5...)
+	}
+	if x.Expires == nil {
+		b = append(b, 0)
+	} else {
+		b = append(b, 1)
+		{
+			t6, err := (*x.Expires).MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			b = binary.AppendUvarint(b, uint64(len(t6)))
+			b = append(b, t6...)
+		}
+	}
+	b = binary.AppendVarint(b, int64(x.TTL))
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(float64(x.Score)))
+	if x.Admin {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, x.Token[:]...)
+	b = binary.AppendUvarint(b, uint64(len(x.Data)))
+	b = append(b, x.Data...)
+	b = binary.AppendUvarint(b, uint64(len(x.Profile.Name)))
+	b = append(b, x.Profile.Name...)
+	b = binary.AppendVarint(b, int64(x.Profile.Age))
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(x.Profile.Ratio)))
+	for _, v7 := range x.Profile.Visits {
+		b = binary.AppendUvarint(b, uint64(v7))
+	}
+	b = binary.AppendUvarint(b, uint64(len(x.Profile.Links)))
+	for _, v8 := range x.Profile.Links {
+		b = binary.AppendUvarint(b, uint64(len(v8.URL)))
+		b = append(b, v8.URL...)
+		b = binary.AppendUvarint(b, uint64(v8.Clicks))
+	}
+	if x.Previous == nil {
+		b = append(b, 0)
+	} else {
+		b = append(b, 1)
+		b = binary.AppendUvarint(b, uint64(len((*x.Previous).Name)))
+		b = append(b, (*x.Previous).Name...)
+		b = binary.AppendVarint(b, int64((*x.Previous).Age))
+		b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32((*x.Previous).Ratio)))
+		for _, v9 := range (*x.Previous).Visits {
+			b = binary.AppendUvarint(b, uint64(v9))
+		}
+		b = binary.AppendUvarint(b, uint64(len((*x.Previous).Links)))
+		for _, v10 := range (*x.Previous).Links {
+			b = binary.AppendUvarint(b, uint64(len(v10.URL)))
+			b = append(b, v10.URL...)
+			b = binary.AppendUvarint(b, uint64(v10.Clicks))
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(x.Limits)))
+	for k11, v12 := range x.Limits {
+		b = binary.AppendUvarint(b, uint64(len(k11)))
+		b = append(b, k11...)
+		b = binary.AppendVarint(b, int64(v12))
+	}
+	return b, nil
+}
+
+// Unmarshal decodes b into x. It implements securecookie.Coder.
+func (x *Session) Unmarshal(b []byte) error {
+	{
+		n13, k14 := binary.Uvarint(b)
+		if k14 <= 0 || n13 > uint64(len(b)-k14) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k14:]
+		x.ID = string(b[:n13])
+		b = b[n13:]
+	}
+	{
+		v15, n16 := binary.Uvarint(b)
+		if n16 <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		x.UserID = v15
+		b = b[n16:]
+	}
+	{
+		n17, k18 := binary.Uvarint(b)
+		if k18 <= 0 || n17 > uint64(len(b)-k18) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k18:]
+		if n17 == 0 {
+			x.Roles = nil
+		} else {
+			x.Roles = make([]Role, n17)
+			for i19 := range x.Roles {
+				{
+					n20, k21 := binary.Uvarint(b)
+					if k21 <= 0 || n20 > uint64(len(b)-k21) {
+						return io.ErrUnexpectedEOF
+					}
+					b = b[k21:]
+					x.Roles[i19] = Role(b[:n20])
+					b = b[n20:]
+				}
+			}
+		}
+	}
+	{
+		n22, k23 := binary.Uvarint(b)
+		if k23 <= 0 || n22 > uint64(len(b)-k23) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k23:]
+		if n22 == 0 {
+			x.Flash = nil
+		} else {
+			x.Flash = make(map[string][]string, n22)
+			for i24 := uint64(0); i24 < n22; i24++ {
+				var k25 string
+				var v26 []string
+				{
+					n27, k28 := binary.Uvarint(b)
+					if k28 <= 0 || n27 > uint64(len(b)-k28) {
+						return io.ErrUnexpectedEOF
+					}
+					b = b[k28:]
+					k25 = string(b[:n27])
+					b = b[n27:]
+				}
+				{
+					n29, k30 := binary.Uvarint(b)
+					if k30 <= 0 || n29 > uint64(len(b)-k30) {
+						return io.ErrUnexpectedEOF
+					}
+					b = b[k30:]
+					if n29 == 0 {
+						v26 = nil
+					} else {
+						v26 = make([]string, n29)
+						for i31 := range v26 {
+							{
+								n32, k33 := binary.Uvarint(b)
+								if k33 <= 0 || n32 > uint64(len(b)-k33) {
+									return io.ErrUnexpectedEOF
+								}
+								b = b[k33:]
+								v26[i31] = string(b[:n32])
+								b = b[n32:]
+							}
+						}
+					}
+				}
+				x.Flash[k25] = v26
+			}
+		}
+	}
+	{
+		n34, k35 := binary.Uvarint(b)
+		if k35 <= 0 || n34 > uint64(len(b)-k35) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k35:]
+		if err := x.Created.UnmarshalBinary(b[:n34]); err != nil {
+			return err
+		}
+		b = b[n34:]
+	}
+	if len(b) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	p36 := b[0]
+	b = b[1:]
+	switch p36 {
+	case 0:
+		x.Expires = nil
+	case 1:
+		x.Expires = new(time.Time)
+		{
+			n37, k38 := binary.Uvarint(b)
+			if k38 <= 0 || n37 > uint64(len(b)-k38) {
+				return io.ErrUnexpectedEOF
+			}
+			b = b[k38:]
+			if err := (*x.Expires).UnmarshalBinary(b[:n37]); err != nil {
+				return err
+			}
+			b = b[n37:]
+		}
+	default:
+		return errors.New("securecookie: invalid Session value")
+	}
+	{
+		v39, n40 := binary.Varint(b)
+		if n40 <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		x.TTL = time.Duration(v39)
+		b = b[n40:]
+	}
+	if len(b) < 8 {
+		return io.ErrUnexpectedEOF
+	}
+	x.Score = math.Float64frombits(binary.BigEndian.Uint64(b))
+	b = b[8:]
+	if len(b) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if b[0] > 1 {
+		return errors.New("securecookie: invalid Session value")
+	}
+	x.Admin = b[0] == 1
+	b = b[1:]
+	if len(b) < len(x.Token) {
+		return io.ErrUnexpectedEOF
+	}
+	b = b[copy(x.Token[:], b):]
+	{
+		n41, k42 := binary.Uvarint(b)
+		if k42 <= 0 || n41 > uint64(len(b)-k42) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k42:]
+		x.Data = append([]byte(nil), b[:n41]...)
+		b = b[n41:]
+	}
+	{
+		n43, k44 := binary.Uvarint(b)
+		if k44 <= 0 || n43 > uint64(len(b)-k44) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k44:]
+		x.Profile.Name = string(b[:n43])
+		b = b[n43:]
+	}
+	{
+		v45, n46 := binary.Varint(b)
+		if n46 <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if int64(int(v45)) != v45 {
+			return errors.New("securecookie: invalid Session value")
+		}
+		x.Profile.Age = int(v45)
+		b = b[n46:]
+	}
+	if len(b) < 4 {
+		return io.ErrUnexpectedEOF
+	}
+	x.Profile.Ratio = math.Float32frombits(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	for i47 := range x.Profile.Visits {
+		{
+			v48, n49 := binary.Uvarint(b)
+			if n49 <= 0 {
+				return io.ErrUnexpectedEOF
+			}
+			if uint64(uint16(v48)) != v48 {
+				return errors.New("securecookie: invalid Session value")
+			}
+			x.Profile.Visits[i47] = uint16(v48)
+			b = b[n49:]
+		}
+	}
+	{
+		n50, k51 := binary.Uvarint(b)
+		if k51 <= 0 || n50 > uint64(len(b)-k51) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k51:]
+		if n50 == 0 {
+			x.Profile.Links = nil
+		} else {
+			x.Profile.Links = make([]struct {
+				URL    string
+				Clicks uint32
+			}, n50)
+			for i52 := range x.Profile.Links {
+				{
+					n53, k54 := binary.Uvarint(b)
+					if k54 <= 0 || n53 > uint64(len(b)-k54) {
+						return io.ErrUnexpectedEOF
+					}
+					b = b[k54:]
+					x.Profile.Links[i52].URL = string(b[:n53])
+					b = b[n53:]
+				}
+				{
+					v55, n56 := binary.Uvarint(b)
+					if n56 <= 0 {
+						return io.ErrUnexpectedEOF
+					}
+					if uint64(uint32(v55)) != v55 {
+						return errors.New("securecookie: invalid Session value")
+					}
+					x.Profile.Links[i52].Clicks = uint32(v55)
+					b = b[n56:]
+				}
+			}
+		}
+	}
+	if len(b) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	p57 := b[0]
+	b = b[1:]
+	switch p57 {
+	case 0:
+		x.Previous = nil
+	case 1:
+		x.Previous = new(Profile)
+		{
+			n58, k59 := binary.Uvarint(b)
+			if k59 <= 0 || n58 > uint64(len(b)-k59) {
+				return io.ErrUnexpectedEOF
+			}
+			b = b[k59:]
+			(*x.Previous).Name = string(b[:n58])
+			b = b[n58:]
+		}
+		{
+			v60, n61 := binary.Varint(b)
+			if n61 <= 0 {
+				return io.ErrUnexpectedEOF
+			}
+			if int64(int(v60)) != v60 {
+				return errors.New("securecookie: invalid Session value")
+			}
+			(*x.Previous).Age = int(v60)
+			b = b[n61:]
+		}
+		if len(b) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+		(*x.Previous).Ratio = math.Float32frombits(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		for i62 := range (*x.Previous).Visits {
+			{
+				v63, n64 := binary.Uvarint(b)
+				if n64 <= 0 {
+					return io.ErrUnexpectedEOF
+				}
+				if uint64(uint16(v63)) != v63 {
+					return errors.New("securecookie: invalid Session value")
+				}
+				(*x.Previous).Visits[i62] = uint16(v63)
+				b = b[n64:]
+			}
+		}
+		{
+			n65, k66 := binary.Uvarint(b)
+			if k66 <= 0 || n65 > uint64(len(b)-k66) {
+				return io.ErrUnexpectedEOF
+			}
+			b = b[k66:]
+			if n65 == 0 {
+				(*x.Previous).Links = nil
+			} else {
+				(*x.Previous).Links = make([]struct {
+					URL    string
+					Clicks uint32
+				}, n65)
+				for i67 := range (*x.Previous).Links {
+					{
+						n68, k69 := binary.Uvarint(b)
+						if k69 <= 0 || n68 > uint64(len(b)-k69) {
+							return io.ErrUnexpectedEOF
+						}
+						b = b[k69:]
+						(*x.Previous).Links[i67].URL = string(b[:n68])
+						b = b[n68:]
+					}
+					{
+						v70, n71 := binary.Uvarint(b)
+						if n71 <= 0 {
+							return io.ErrUnexpectedEOF
+						}
+						if uint64(uint32(v70)) != v70 {
+							return errors.New("securecookie: invalid Session value")
+						}
+						(*x.Previous).Links[i67].Clicks = uint32(v70)
+						b = b[n71:]
+					}
+				}
+			}
+		}
+	default:
+		return errors.New("securecookie: invalid Session value")
+	}
+	{
+		n72, k73 := binary.Uvarint(b)
+		if k73 <= 0 || n72 > uint64(len(b)-k73) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k73:]
+		if n72 == 0 {
+			x.Limits = nil
+		} else {
+			x.Limits = make(map[string]int8, n72)
+			for i74 := uint64(0); i74 < n72; i74++ {
+				var k75 string
+				var v76 int8
+				{
+					n77, k78 := binary.Uvarint(b)
+					if k78 <= 0 || n77 > uint64(len(b)-k78) {
+						return io.ErrUnexpectedEOF
+					}
+					b = b[k78:]
+					k75 = string(b[:n77])
+					b = b[n77:]
+				}
+				{
+					v79, n80 := binary.Varint(b)
+					if n80 <= 0 {
+						return io.ErrUnexpectedEOF
+					}
+					if int64(int8(v79)) != v79 {
+						return errors.New("securecookie: invalid Session value")
+					}
+					v76 = int8(v79)
+					b = b[n80:]
+				}
+				x.Limits[k75] = v76
+			}
+		}
+	}
+	if len(b) != 0 {
+		return errors.New("securecookie: invalid Session value")
+	}
+	return nil
+}
+
+// Marshal encodes x. It implements securecookie.Coder.
+func (x *Profile) Marshal() ([]byte, error) {
+	var b []byte
+	b = binary.AppendUvarint(b, uint64(len(x.Name)))
+	b = append(b, x.Name...)
+	b = binary.AppendVarint(b, int64(x.Age))
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(x.Ratio)))
+	for _, v1 := range x.Visits {
+		b = binary.AppendUvarint(b, uint64(v1))
+	}
+	b = binary.AppendUvarint(b, uint64(len(x.Links)))
+	for _, v2 := range x.Links {
+		b = binary.AppendUvarint(b, uint64(len(v2.URL)))
+		b = append(b, v2.URL...)
+		b = binary.AppendUvarint(b, uint64(v2.Clicks))
+	}
+	return b, nil
+}
+
+// Unmarshal decodes b into x. It implements securecookie.Coder.
+func (x *Profile) Unmarshal(b []byte) error {
+	{
+		n3, k4 := binary.Uvarint(b)
+		if k4 <= 0 || n3 > uint64(len(b)-k4) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k4:]
+		x.Name = string(b[:n3])
+		b = b[n3:]
+	}
+	{
+		v5, n6 := binary.Varint(b)
+		if n6 <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if int64(int(v5)) != v5 {
+			return errors.New("securecookie: invalid Profile value")
+		}
+		x.Age = int(v5)
+		b = b[n6:]
+	}
+	if len(b) < 4 {
+		return io.ErrUnexpectedEOF
+	}
+	x.Ratio = math.Float32frombits(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	for i7 := range x.Visits {
+		{
+			v8, n9 := binary.Uvarint(b)
+			if n9 <= 0 {
+				return io.ErrUnexpectedEOF
+			}
+			if uint64(uint16(v8)) != v8 {
+				return errors.New("securecookie: invalid Profile value")
+			}
+			x.Visits[i7] = uint16(v8)
+			b = b[n9:]
+		}
+	}
+	{
+		n10, k11 := binary.Uvarint(b)
+		if k11 <= 0 || n10 > uint64(len(b)-k11) {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[k11:]
+		if n10 == 0 {
+			x.Links = nil
+		} else {
+			x.Links = make([]struct {
+				URL    string
+				Clicks uint32
+			}, n10)
+			for i12 := range x.Links {
+				{
+					n13, k14 := binary.Uvarint(b)
+					if k14 <= 0 || n13 > uint64(len(b)-k14) {
+						return io.ErrUnexpectedEOF
+					}
+					b = b[k14:]
+					x.Links[i12].URL = string(b[:n13])
+					b = b[n13:]
+				}
+				{
+					v15, n16 := binary.Uvarint(b)
+					if n16 <= 0 {
+						return io.ErrUnexpectedEOF
+					}
+					if uint64(uint32(v15)) != v15 {
+						return errors.New("securecookie: invalid Profile value")
+					}
+					x.Links[i12].Clicks = uint32(v15)
+					b = b[n16:]
+				}
+			}
+		}
+	}
+	if len(b) != 0 {
+		return errors.New("securecookie: invalid Profile value")
+	}
+	return nil
+}
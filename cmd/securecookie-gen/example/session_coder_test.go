@@ -0,0 +1,137 @@
+// Code generated by securecookie-gen; DO NOT EDIT.
+
+package example
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionCoder(t *testing.T) {
+	sample := &Session{}
+	sample.ID = "value"
+	sample.UserID = 42
+	sample.Roles = make([]Role, 2)
+	for i1 := range sample.Roles {
+		sample.Roles[i1] = "value"
+	}
+	sample.Flash = make(map[string][]string)
+	{
+		var k2 string
+		var v3 []string
+		k2 = "value"
+		v3 = make([]string, 2)
+		for i4 := range v3 {
+			v3[i4] = "value"
+		}
+		sample.Flash[k2] = v3
+	}
+	sample.Created = time.Unix(1600000000, 123456789).UTC()
+	sample.Expires = new(time.Time)
+	(*sample.Expires) = time.Unix(1600000000, 123456789).UTC()
+	sample.TTL = -42
+	sample.Score = 1.5
+	sample.Admin = true
+	for i5 := range sample.Token {
+		sample.Token[i5] = 42
+	}
+	sample.Data = []byte("value")
+	sample.Profile.Name = "value"
+	sample.Profile.Age = -42
+	sample.Profile.Ratio = 1.5
+	for i6 := range sample.Profile.Visits {
+		sample.Profile.Visits[i6] = 42
+	}
+	sample.Profile.Links = make([]struct {
+		URL    string
+		Clicks uint32
+	}, 2)
+	for i7 := range sample.Profile.Links {
+		sample.Profile.Links[i7].URL = "value"
+		sample.Profile.Links[i7].Clicks = 42
+	}
+	sample.Previous = new(Profile)
+	(*sample.Previous).Name = "value"
+	(*sample.Previous).Age = -42
+	(*sample.Previous).Ratio = 1.5
+	for i8 := range (*sample.Previous).Visits {
+		(*sample.Previous).Visits[i8] = 42
+	}
+	(*sample.Previous).Links = make([]struct {
+		URL    string
+		Clicks uint32
+	}, 2)
+	for i9 := range (*sample.Previous).Links {
+		(*sample.Previous).Links[i9].URL = "value"
+		(*sample.Previous).Links[i9].Clicks = 42
+	}
+	sample.Limits = make(map[string]int8)
+	{
+		var k10 string
+		var v11 int8
+		k10 = "value"
+		v11 = -42
+		sample.Limits[k10] = v11
+	}
+	for _, src := range []*Session{{}, sample} {
+		b, err := src.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := &Session{}
+		if err = dst.Unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, src) {
+			t.Errorf("Expected %+v, got %+v.", src, dst)
+		}
+		for i := range b {
+			if err = dst.Unmarshal(b[:i]); err == nil {
+				t.Errorf("Expected an error for %d bytes out of %d.", i, len(b))
+			}
+		}
+		if err = dst.Unmarshal(append(b, 0)); err == nil {
+			t.Errorf("Expected an error for trailing data.")
+		}
+	}
+}
+
+func TestProfileCoder(t *testing.T) {
+	sample := &Profile{}
+	sample.Name = "value"
+	sample.Age = -42
+	sample.Ratio = 1.5
+	for i1 := range sample.Visits {
+		sample.Visits[i1] = 42
+	}
+	sample.Links = make([]struct {
+		URL    string
+		Clicks uint32
+	}, 2)
+	for i2 := range sample.Links {
+		sample.Links[i2].URL = "value"
+		sample.Links[i2].Clicks = 42
+	}
+	for _, src := range []*Profile{{}, sample} {
+		b, err := src.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := &Profile{}
+		if err = dst.Unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, src) {
+			t.Errorf("Expected %+v, got %+v.", src, dst)
+		}
+		for i := range b {
+			if err = dst.Unmarshal(b[:i]); err == nil {
+				t.Errorf("Expected an error for %d bytes out of %d.", i, len(b))
+			}
+		}
+		if err = dst.Unmarshal(append(b, 0)); err == nil {
+			t.Errorf("Expected an error for trailing data.")
+		}
+	}
+}
@@ -0,0 +1,715 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command securecookie-gen writes Marshal and Unmarshal methods for struct
+// types, so that they implement securecookie.Coder and are encoded without
+// reflection.
+//
+// It is meant to be run by go generate, from the package that defines the
+// types:
+//
+//	//go:generate securecookie-gen -type=Session,Profile
+//
+// The methods are written to <type>_coder.go, named after the first type,
+// and tests that round-trip each type to <type>_coder_test.go.
+//
+// Fields may be booleans, integers, floats, strings, byte slices and arrays,
+// time.Time and time.Duration values, structs, and pointers (for optional
+// fields), slices, arrays and maps of these. Types declared in the package
+// with one of these as underlying type are supported too. Unexported fields
+// are encoded; fields tagged with `securecookie:"-"` are skipped.
+//
+// The encoding is compact: integers are varints, strings, slices and maps
+// are prefixed with their length, floats use a fixed size, pointers with a
+// presence byte, and time.Time values their MarshalBinary form. Fields are
+// encoded in order, without names, so values must be decoded by code
+// generated from the same definition. Unmarshal checks every length against
+// the remaining input, and rejects values that overflow their type and
+// trailing data.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default <type>_coder.go")
+	tests     = flag.Bool("tests", true, "write round-trip tests to the output file name with a _test suffix")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: securecookie-gen -type=T[,T...] [flags] [directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("securecookie-gen: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	name := *output
+	if name == "" {
+		name = strings.ToLower(names[0]) + "_coder.go"
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	code, test, err := generate(pkg, names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(name, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+	if *tests {
+		name = strings.TrimSuffix(name, ".go") + "_test.go"
+		if err = os.WriteFile(name, test, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// Parsing --------------------------------------------------------------------
+
+// pkgInfo holds the type declarations of a package.
+type pkgInfo struct {
+	name  string
+	decls map[string]*ast.TypeSpec
+}
+
+// loadPackage parses the non-test Go files of a directory.
+func loadPackage(dir string) (*pkgInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &pkgInfo{decls: make(map[string]*ast.TypeSpec)}
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = f.Name.Name
+		} else if pkg.name != f.Name.Name {
+			return nil, fmt.Errorf("found packages %s and %s in %s", pkg.name, f.Name.Name, dir)
+		}
+		for _, d := range f.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, s := range gd.Specs {
+					ts := s.(*ast.TypeSpec)
+					pkg.decls[ts.Name.Name] = ts
+				}
+			}
+		}
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+type kind int
+
+const (
+	kBool kind = iota
+	kInt
+	kUint
+	kFloat
+	kString
+	kBytes
+	kTime
+	kStruct
+	kPtr
+	kSlice
+	kArray
+	kMap
+)
+
+// typ describes how a type is encoded.
+type typ struct {
+	kind  kind
+	name  string // Go expression of the type
+	basic string // underlying basic type, for scalars
+	bits  int    // size of floats
+	time  bool   // whether name refers to package time
+	key   *typ
+	elem  *typ
+	field []field
+}
+
+type field struct {
+	name string
+	typ  *typ
+}
+
+var basicTypes = map[string]typ{
+	"bool":    {kind: kBool},
+	"int":     {kind: kInt},
+	"int8":    {kind: kInt},
+	"int16":   {kind: kInt},
+	"int32":   {kind: kInt},
+	"rune":    {kind: kInt},
+	"int64":   {kind: kInt},
+	"uint":    {kind: kUint},
+	"uint8":   {kind: kUint},
+	"byte":    {kind: kUint},
+	"uint16":  {kind: kUint},
+	"uint32":  {kind: kUint},
+	"uint64":  {kind: kUint},
+	"float32": {kind: kFloat, bits: 32},
+	"float64": {kind: kFloat, bits: 64},
+	"string":  {kind: kString},
+}
+
+// resolve returns the description of a type expression. The stack holds the
+// named types being resolved, to reject recursive types.
+func (p *pkgInfo) resolve(expr ast.Expr, stack []string) (*typ, error) {
+	name := types.ExprString(expr)
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		return p.resolve(x.X, stack)
+	case *ast.Ident:
+		if b, ok := basicTypes[x.Name]; ok {
+			b.name, b.basic = x.Name, x.Name
+			return &b, nil
+		}
+		ts, ok := p.decls[x.Name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", x.Name)
+		}
+		if ts.TypeParams != nil {
+			return nil, fmt.Errorf("generic type %s is not supported", x.Name)
+		}
+		for _, s := range stack {
+			if s == x.Name {
+				return nil, fmt.Errorf("recursive type %s is not supported", x.Name)
+			}
+		}
+		t, err := p.resolve(ts.Type, append(stack, x.Name))
+		if err != nil {
+			return nil, err
+		}
+		if ts.Assign.IsValid() {
+			// Aliases keep the name of their target.
+			return t, nil
+		}
+		named := *t
+		named.name, named.time = x.Name, false
+		return &named, nil
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok && pkg.Name == "time" {
+			switch x.Sel.Name {
+			case "Time":
+				return &typ{kind: kTime, name: name, time: true}, nil
+			case "Duration":
+				return &typ{kind: kInt, name: name, basic: "int64", time: true}, nil
+			}
+		}
+	case *ast.StarExpr:
+		elem, err := p.resolve(x.X, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &typ{kind: kPtr, name: name, time: elem.time, elem: elem}, nil
+	case *ast.ArrayType:
+		elem, err := p.resolve(x.Elt, stack)
+		if err != nil {
+			return nil, err
+		}
+		if x.Len == nil {
+			if isByte(elem) {
+				return &typ{kind: kBytes, name: name}, nil
+			}
+			return &typ{kind: kSlice, name: name, time: elem.time, elem: elem}, nil
+		}
+		if _, ok := x.Len.(*ast.Ellipsis); ok {
+			break
+		}
+		return &typ{kind: kArray, name: name, time: elem.time, elem: elem}, nil
+	case *ast.MapType:
+		key, err := p.resolve(x.Key, stack)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := p.resolve(x.Value, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &typ{kind: kMap, name: name, time: key.time || elem.time, key: key, elem: elem}, nil
+	case *ast.StructType:
+		t := &typ{kind: kStruct, name: name}
+		for _, f := range x.Fields.List {
+			if f.Tag != nil {
+				tag, _ := strconv.Unquote(f.Tag.Value)
+				if reflect.StructTag(tag).Get("securecookie") == "-" {
+					continue
+				}
+			}
+			ft, err := p.resolve(f.Type, stack)
+			if err != nil {
+				return nil, err
+			}
+			t.time = t.time || ft.time
+			if len(f.Names) == 0 {
+				// Embedded fields are named after their type.
+				e := f.Type
+				if s, ok := e.(*ast.StarExpr); ok {
+					e = s.X
+				}
+				if s, ok := e.(*ast.SelectorExpr); ok {
+					e = s.Sel
+				}
+				t.field = append(t.field, field{name: e.(*ast.Ident).Name, typ: ft})
+			}
+			for _, n := range f.Names {
+				if n.Name != "_" {
+					t.field = append(t.field, field{name: n.Name, typ: ft})
+				}
+			}
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("type %s is not supported", name)
+}
+
+// Generation -----------------------------------------------------------------
+
+// generator writes Go source, and records the packages it uses.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+	typ     string // name of the type whose methods are written
+	tmp     int
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format+"\n", args...)
+}
+
+// use records that the generated code uses a package.
+func (g *generator) use(pkg string) {
+	g.imports[pkg] = true
+}
+
+// name returns the name of a type, recording the use of package time.
+func (g *generator) name(t *typ) string {
+	if t.time {
+		g.use("time")
+	}
+	return t.name
+}
+
+// temp returns a new name for a temporary variable.
+func (g *generator) temp(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+// source returns the formatted source written so far, with a header.
+func (g *generator) source(pkg string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by securecookie-gen; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		paths := make([]string, 0, len(g.imports))
+		for p := range g.imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		out.WriteString("import (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&out, "%q\n", p)
+		}
+		out.WriteString(")\n")
+	}
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// generate returns the source of the methods of the named struct types, and
+// of their tests.
+func generate(pkg *pkgInfo, names []string) (code, test []byte, err error) {
+	cg := &generator{imports: make(map[string]bool)}
+	tg := &generator{imports: map[string]bool{"reflect": true, "testing": true}}
+	for _, name := range names {
+		t, err := pkg.resolve(ast.NewIdent(name), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.kind != kStruct {
+			return nil, nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		cg.typ, cg.tmp = name, 0
+		cg.printf("\n// Marshal encodes x. It implements securecookie.Coder.")
+		cg.printf("func (x *%s) Marshal() ([]byte, error) {", name)
+		cg.printf("var b []byte")
+		cg.encode("x", t)
+		cg.printf("return b, nil")
+		cg.printf("}")
+		cg.printf("\n// Unmarshal decodes b into x. It implements securecookie.Coder.")
+		cg.printf("func (x *%s) Unmarshal(b []byte) error {", name)
+		cg.decode("x", t)
+		cg.printf("if len(b) != 0 {")
+		cg.printf("return %s", cg.invalid())
+		cg.printf("}")
+		cg.printf("return nil")
+		cg.printf("}")
+
+		tg.typ, tg.tmp = name, 0
+		tg.test(t)
+	}
+	if code, err = cg.source(pkg.name); err != nil {
+		return nil, nil, err
+	}
+	if test, err = tg.source(pkg.name); err != nil {
+		return nil, nil, err
+	}
+	return code, test, nil
+}
+
+// invalid returns an expression of the error returned for invalid input.
+func (g *generator) invalid() string {
+	g.use("errors")
+	return fmt.Sprintf("errors.New(%q)", "securecookie: invalid "+g.typ+" value")
+}
+
+// convert returns expr converted to the type of t, if needed.
+func (g *generator) convert(t *typ, expr string) string {
+	if t.name == t.basic {
+		return expr
+	}
+	return g.name(t) + "(" + expr + ")"
+}
+
+// encode writes statements that append the encoding of expr to b.
+func (g *generator) encode(expr string, t *typ) {
+	switch t.kind {
+	case kBool:
+		g.printf("if %s {", expr)
+		g.printf("b = append(b, 1)")
+		g.printf("} else {")
+		g.printf("b = append(b, 0)")
+		g.printf("}")
+	case kInt:
+		g.use("encoding/binary")
+		g.printf("b = binary.AppendVarint(b, int64(%s))", expr)
+	case kUint:
+		g.use("encoding/binary")
+		g.printf("b = binary.AppendUvarint(b, uint64(%s))", expr)
+	case kFloat:
+		g.use("encoding/binary")
+		g.use("math")
+		g.printf("b = binary.BigEndian.AppendUint%d(b, math.Float%dbits(float%d(%s)))", t.bits, t.bits, t.bits, expr)
+	case kString, kBytes:
+		g.use("encoding/binary")
+		g.printf("b = binary.AppendUvarint(b, uint64(len(%s)))", expr)
+		if t.kind == kString && t.name != "string" {
+			expr = "string(" + expr + ")"
+		}
+		g.printf("b = append(b, %s...)", expr)
+	case kTime:
+		g.use("encoding/binary")
+		v := g.temp("t")
+		g.printf("{")
+		g.printf("%s, err := %s.MarshalBinary()", v, expr)
+		g.printf("if err != nil {")
+		g.printf("return nil, err")
+		g.printf("}")
+		g.printf("b = binary.AppendUvarint(b, uint64(len(%s)))", v)
+		g.printf("b = append(b, %s...)", v)
+		g.printf("}")
+	case kStruct:
+		for _, f := range t.field {
+			g.encode(expr+"."+f.name, f.typ)
+		}
+	case kPtr:
+		g.printf("if %s == nil {", expr)
+		g.printf("b = append(b, 0)")
+		g.printf("} else {")
+		g.printf("b = append(b, 1)")
+		g.encode("(*"+expr+")", t.elem)
+		g.printf("}")
+	case kSlice, kArray:
+		if t.kind == kSlice {
+			g.use("encoding/binary")
+			g.printf("b = binary.AppendUvarint(b, uint64(len(%s)))", expr)
+		} else if isByte(t.elem) {
+			g.printf("b = append(b, %s[:]...)", expr)
+			return
+		}
+		v := g.temp("v")
+		g.printf("for _, %s := range %s {", v, expr)
+		g.encode(v, t.elem)
+		g.printf("}")
+	case kMap:
+		g.use("encoding/binary")
+		g.printf("b = binary.AppendUvarint(b, uint64(len(%s)))", expr)
+		k, v := g.temp("k"), g.temp("v")
+		g.printf("for %s, %s := range %s {", k, v, expr)
+		g.encode(k, t.key)
+		g.encode(v, t.elem)
+		g.printf("}")
+	}
+}
+
+// isByte returns whether t is byte or uint8, whose arrays are copied as is.
+func isByte(t *typ) bool {
+	return t.name == "byte" || t.name == "uint8"
+}
+
+// length writes statements that read a length prefix into n, checking that
+// at least n bytes follow it, and advance b past it.
+func (g *generator) length() string {
+	g.use("encoding/binary")
+	g.use("io")
+	n, k := g.temp("n"), g.temp("k")
+	g.printf("%s, %s := binary.Uvarint(b)", n, k)
+	g.printf("if %s <= 0 || %s > uint64(len(b)-%s) {", k, n, k)
+	g.printf("return io.ErrUnexpectedEOF")
+	g.printf("}")
+	g.printf("b = b[%s:]", k)
+	return n
+}
+
+// decode writes statements that decode the start of b into lhs, and advance
+// b past it.
+func (g *generator) decode(lhs string, t *typ) {
+	switch t.kind {
+	case kBool:
+		g.use("io")
+		g.printf("if len(b) == 0 {")
+		g.printf("return io.ErrUnexpectedEOF")
+		g.printf("}")
+		g.printf("if b[0] > 1 {")
+		g.printf("return %s", g.invalid())
+		g.printf("}")
+		g.printf("%s = %s", lhs, g.convert(t, "b[0] == 1"))
+		g.printf("b = b[1:]")
+	case kInt, kUint:
+		g.use("encoding/binary")
+		g.use("io")
+		wide, read := "int64", "Varint"
+		if t.kind == kUint {
+			wide, read = "uint64", "Uvarint"
+		}
+		v, n := g.temp("v"), g.temp("n")
+		g.printf("{")
+		g.printf("%s, %s := binary.%s(b)", v, n, read)
+		g.printf("if %s <= 0 {", n)
+		g.printf("return io.ErrUnexpectedEOF")
+		g.printf("}")
+		if t.basic != wide {
+			g.printf("if %s(%s(%s)) != %s {", wide, t.basic, v, v)
+			g.printf("return %s", g.invalid())
+			g.printf("}")
+		}
+		if t.name == t.basic && t.basic != wide {
+			v = t.basic + "(" + v + ")"
+		}
+		g.printf("%s = %s", lhs, g.convert(t, v))
+		g.printf("b = b[%s:]", n)
+		g.printf("}")
+	case kFloat:
+		g.use("encoding/binary")
+		g.use("io")
+		g.use("math")
+		size := t.bits / 8
+		g.printf("if len(b) < %d {", size)
+		g.printf("return io.ErrUnexpectedEOF")
+		g.printf("}")
+		g.printf("%s = %s", lhs, g.convert(t, fmt.Sprintf("math.Float%dfrombits(binary.BigEndian.Uint%d(b))", t.bits, t.bits)))
+		g.printf("b = b[%d:]", size)
+	case kString, kBytes, kTime:
+		g.printf("{")
+		n := g.length()
+		data := "b[:" + n + "]"
+		switch t.kind {
+		case kString:
+			g.printf("%s = %s(%s)", lhs, g.name(t), data)
+		case kBytes:
+			g.printf("%s = append(%s(nil), %s...)", lhs, g.name(t), data)
+		case kTime:
+			g.printf("if err := %s.UnmarshalBinary(%s); err != nil {", lhs, data)
+			g.printf("return err")
+			g.printf("}")
+		}
+		g.printf("b = b[%s:]", n)
+		g.printf("}")
+	case kStruct:
+		for _, f := range t.field {
+			g.decode(lhs+"."+f.name, f.typ)
+		}
+	case kPtr:
+		g.use("io")
+		g.printf("if len(b) == 0 {")
+		g.printf("return io.ErrUnexpectedEOF")
+		g.printf("}")
+		p := g.temp("p")
+		g.printf("%s := b[0]", p)
+		g.printf("b = b[1:]")
+		g.printf("switch %s {", p)
+		g.printf("case 0:")
+		g.printf("%s = nil", lhs)
+		g.printf("case 1:")
+		g.printf("%s = new(%s)", lhs, g.name(t.elem))
+		g.decode("(*"+lhs+")", t.elem)
+		g.printf("default:")
+		g.printf("return %s", g.invalid())
+		g.printf("}")
+	case kSlice:
+		g.printf("{")
+		n := g.length()
+		g.printf("if %s == 0 {", n)
+		g.printf("%s = nil", lhs)
+		g.printf("} else {")
+		g.printf("%s = make(%s, %s)", lhs, g.name(t), n)
+		i := g.temp("i")
+		g.printf("for %s := range %s {", i, lhs)
+		g.decode(lhs+"["+i+"]", t.elem)
+		g.printf("}")
+		g.printf("}")
+		g.printf("}")
+	case kArray:
+		if isByte(t.elem) {
+			g.use("io")
+			g.printf("if len(b) < len(%s) {", lhs)
+			g.printf("return io.ErrUnexpectedEOF")
+			g.printf("}")
+			g.printf("b = b[copy(%s[:], b):]", lhs)
+			return
+		}
+		i := g.temp("i")
+		g.printf("for %s := range %s {", i, lhs)
+		g.decode(lhs+"["+i+"]", t.elem)
+		g.printf("}")
+	case kMap:
+		g.printf("{")
+		n := g.length()
+		g.printf("if %s == 0 {", n)
+		g.printf("%s = nil", lhs)
+		g.printf("} else {")
+		g.printf("%s = make(%s, %s)", lhs, g.name(t), n)
+		i, k, v := g.temp("i"), g.temp("k"), g.temp("v")
+		g.printf("for %s := uint64(0); %s < %s; %s++ {", i, i, n, i)
+		g.printf("var %s %s", k, g.name(t.key))
+		g.printf("var %s %s", v, g.name(t.elem))
+		g.decode(k, t.key)
+		g.decode(v, t.elem)
+		g.printf("%s[%s] = %s", lhs, k, v)
+		g.printf("}")
+		g.printf("}")
+		g.printf("}")
+	}
+}
+
+// test writes a test that round-trips the zero value and a sample value of
+// a type, and checks that truncated and extended encodings are rejected.
+func (g *generator) test(t *typ) {
+	g.printf("\nfunc Test%sCoder(t *testing.T) {", g.typ)
+	g.printf("sample := &%s{}", g.typ)
+	g.fill("sample", t)
+	g.printf("for _, src := range []*%s{{}, sample} {", g.typ)
+	g.printf("b, err := src.Marshal()")
+	g.printf("if err != nil {")
+	g.printf("t.Fatal(err)")
+	g.printf("}")
+	g.printf("dst := &%s{}", g.typ)
+	g.printf("if err = dst.Unmarshal(b); err != nil {")
+	g.printf("t.Fatal(err)")
+	g.printf("}")
+	g.printf("if !reflect.DeepEqual(dst, src) {")
+	g.printf("t.Errorf(\"Expected %%+v, got %%+v.\", src, dst)")
+	g.printf("}")
+	g.printf("for i := range b {")
+	g.printf("if err = dst.Unmarshal(b[:i]); err == nil {")
+	g.printf("t.Errorf(\"Expected an error for %%d bytes out of %%d.\", i, len(b))")
+	g.printf("}")
+	g.printf("}")
+	g.printf("if err = dst.Unmarshal(append(b, 0)); err == nil {")
+	g.printf("t.Errorf(\"Expected an error for trailing data.\")")
+	g.printf("}")
+	g.printf("}")
+	g.printf("}")
+}
+
+// fill writes statements that set lhs to a value without zero parts.
+func (g *generator) fill(lhs string, t *typ) {
+	switch t.kind {
+	case kBool:
+		g.printf("%s = true", lhs)
+	case kInt:
+		g.printf("%s = -42", lhs)
+	case kUint:
+		g.printf("%s = 42", lhs)
+	case kFloat:
+		g.printf("%s = 1.5", lhs)
+	case kString:
+		g.printf("%s = %q", lhs, "value")
+	case kBytes:
+		g.printf("%s = %s(%q)", lhs, g.name(t), "value")
+	case kTime:
+		g.use("time")
+		g.printf("%s = time.Unix(1600000000, 123456789).UTC()", lhs)
+	case kStruct:
+		for _, f := range t.field {
+			g.fill(lhs+"."+f.name, f.typ)
+		}
+	case kPtr:
+		g.printf("%s = new(%s)", lhs, g.name(t.elem))
+		g.fill("(*"+lhs+")", t.elem)
+	case kSlice, kArray:
+		if t.kind == kSlice {
+			g.printf("%s = make(%s, 2)", lhs, g.name(t))
+		}
+		i := g.temp("i")
+		g.printf("for %s := range %s {", i, lhs)
+		g.fill(lhs+"["+i+"]", t.elem)
+		g.printf("}")
+	case kMap:
+		k, v := g.temp("k"), g.temp("v")
+		g.printf("%s = make(%s)", lhs, g.name(t))
+		g.printf("{")
+		g.printf("var %s %s", k, g.name(t.key))
+		g.printf("var %s %s", v, g.name(t.elem))
+		g.fill(k, t.key)
+		g.fill(v, t.elem)
+		g.printf("%s[%s] = %s", lhs, k, v)
+		g.printf("}")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate checks that the files of the example package, whose own tests
+// round-trip the generated types, are up to date.
+func TestGenerate(t *testing.T) {
+	pkg, err := loadPackage("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, test, err := generate(pkg, []string{"Session", "Profile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string][]byte{
+		"session_coder.go":      code,
+		"session_coder_test.go": test,
+	} {
+		want, err := os.ReadFile(filepath.Join("example", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(src, want) {
+			t.Errorf("%s is out of date; run go generate in the example directory.", name)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+import "time"
+
+type Node struct{ Children []*Node }
+type Handler struct{ F func() }
+type Local struct{ Loc *time.Location }
+type List[T any] struct{ Items []T }
+type Name string
+`
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"Node":    "recursive type Node",
+		"Handler": "type func() is not supported",
+		"Local":   "type time.Location is not supported",
+		"List":    "generic type List",
+		"Name":    "type Name is not a struct",
+		"Missing": "type Missing not found",
+	} {
+		_, _, err := generate(pkg, []string{name})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %v, got %v.", expected, err)
+		}
+	}
+}
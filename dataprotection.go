@@ -0,0 +1,490 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dataProtectionMagic is the magic header of payloads protected by
+// ASP.NET Core Data Protection.
+const dataProtectionMagic = 0x09F0C9F0
+
+const dataProtectionKeyModifierSize = 16
+
+var (
+	ErrDataProtectionKey       = errors.New("securecookie: unknown or revoked data protection key")
+	ErrDataProtectionAlgorithm = errors.New("securecookie: unsupported data protection algorithm")
+	ErrDataProtectionHeader    = errors.New("securecookie: invalid data protection header")
+)
+
+// DataProtectionCookiePurposes returns the purposes used by the ASP.NET Core
+// cookie authentication handler to protect the cookies of a scheme, such
+// as "Cookies" or "Identity.Application".
+func DataProtectionCookiePurposes(scheme string) []string {
+	return []string{
+		"Microsoft.AspNetCore.Authentication.Cookies.CookieAuthenticationMiddleware",
+		scheme,
+		"v2",
+	}
+}
+
+// DataProtectionKey is a key of an ASP.NET Core Data Protection key ring.
+type DataProtectionKey struct {
+	// ID is the key GUID, such as "80732141-ec8f-4b80-af9c-c4d2d1ff8901".
+	ID         string
+	Creation   time.Time
+	Activation time.Time
+	Expiration time.Time
+	// Encryption is the encryption algorithm, such as "AES_256_CBC".
+	Encryption string
+	// Validation is the validation algorithm, such as "HMACSHA256".
+	Validation string
+	// MasterKey is the key derivation key.
+	MasterKey []byte
+	Revoked   bool
+}
+
+// dataProtectionKeyXML is the XML representation of a key, as stored in
+// the key-{id}.xml files of a key ring.
+type dataProtectionKeyXML struct {
+	XMLName    xml.Name  `xml:"key"`
+	ID         string    `xml:"id,attr"`
+	Creation   time.Time `xml:"creationDate"`
+	Activation time.Time `xml:"activationDate"`
+	Expiration time.Time `xml:"expirationDate"`
+	Descriptor struct {
+		Descriptor struct {
+			Encryption struct {
+				Algorithm string `xml:"algorithm,attr"`
+			} `xml:"encryption"`
+			Validation struct {
+				Algorithm string `xml:"algorithm,attr"`
+			} `xml:"validation"`
+			MasterKey struct {
+				Value           string    `xml:"value"`
+				EncryptedSecret *struct{} `xml:"encryptedSecret"`
+			} `xml:"masterKey"`
+		} `xml:"descriptor"`
+	} `xml:"descriptor"`
+}
+
+// dataProtectionRevocationXML is the XML representation of a revocation,
+// as stored in the revocation-*.xml files of a key ring.
+type dataProtectionRevocationXML struct {
+	XMLName xml.Name  `xml:"revocation"`
+	Date    time.Time `xml:"revocationDate"`
+	Key     struct {
+		ID string `xml:"id,attr"`
+	} `xml:"key"`
+}
+
+// ParseDataProtectionKey parses the XML of a key, as stored in a key ring.
+//
+// Only keys with an unencrypted master key are supported; keys protected
+// with DPAPI, a certificate or Azure Key Vault must be exported unencrypted.
+func ParseDataProtectionKey(data []byte) (*DataProtectionKey, error) {
+	var x dataProtectionKeyXML
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	d := x.Descriptor.Descriptor
+	if d.MasterKey.EncryptedSecret != nil {
+		return nil, errors.New("securecookie: encrypted data protection keys are not supported")
+	}
+	masterKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(d.MasterKey.Value))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = parseGUID(x.ID); err != nil {
+		return nil, err
+	}
+	return &DataProtectionKey{
+		ID:         x.ID,
+		Creation:   x.Creation,
+		Activation: x.Activation,
+		Expiration: x.Expiration,
+		Encryption: d.Encryption.Algorithm,
+		Validation: d.Validation.Algorithm,
+		MasterKey:  masterKey,
+	}, nil
+}
+
+// LoadDataProtectionKeyRing loads the keys of a key ring stored in a
+// directory, as done by the file system key repository. Revocations found
+// in the directory are applied to the keys.
+func LoadDataProtectionKeyRing(dir string) ([]*DataProtectionKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []*DataProtectionKey
+	revoked := make(map[string]bool)
+	var revokedBefore time.Time
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var root struct {
+			XMLName xml.Name
+		}
+		if err = xml.Unmarshal(data, &root); err != nil {
+			return nil, err
+		}
+		switch root.XMLName.Local {
+		case "key":
+			key, err := ParseDataProtectionKey(data)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "revocation":
+			var r dataProtectionRevocationXML
+			if err = xml.Unmarshal(data, &r); err != nil {
+				return nil, err
+			}
+			if r.Key.ID == "*" {
+				if r.Date.After(revokedBefore) {
+					revokedBefore = r.Date
+				}
+			} else {
+				revoked[strings.ToLower(r.Key.ID)] = true
+			}
+		}
+	}
+	for _, key := range keys {
+		if revoked[strings.ToLower(key.ID)] || !key.Creation.After(revokedBefore) {
+			key.Revoked = true
+		}
+	}
+	return keys, nil
+}
+
+// dataProtectionKey is a key ready to protect and unprotect payloads.
+type dataProtectionKey struct {
+	*DataProtectionKey
+	id            [16]byte
+	encKeySize    int
+	hashFunc      func() hash.Hash
+	contextHeader []byte
+}
+
+// newDataProtectionKey prepares a key for use, computing the context
+// header of its algorithms.
+func newDataProtectionKey(key *DataProtectionKey) (*dataProtectionKey, error) {
+	id, err := parseGUID(key.ID)
+	if err != nil {
+		return nil, err
+	}
+	k := &dataProtectionKey{DataProtectionKey: key, id: id}
+	switch strings.ToUpper(key.Encryption) {
+	case "", "AES_256_CBC":
+		k.encKeySize = 32
+	case "AES_192_CBC":
+		k.encKeySize = 24
+	case "AES_128_CBC":
+		k.encKeySize = 16
+	default:
+		return nil, ErrDataProtectionAlgorithm
+	}
+	switch strings.ToUpper(key.Validation) {
+	case "", "HMACSHA256":
+		k.hashFunc = sha256.New
+	case "HMACSHA512":
+		k.hashFunc = sha512.New
+	default:
+		return nil, ErrDataProtectionAlgorithm
+	}
+	// The context header describes the algorithms, followed by the output
+	// of both algorithms for empty inputs under keys derived from an empty
+	// key derivation key.
+	macSize := k.hashFunc().Size()
+	tempKeys := sp800108(nil, nil, nil, k.encKeySize+macSize)
+	block, err := aes.NewCipher(tempKeys[:k.encKeySize])
+	if err != nil {
+		return nil, err
+	}
+	empty := pkcs7Pad(nil, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(empty, empty)
+	h := []byte{0, 0}
+	h = binary.BigEndian.AppendUint32(h, uint32(k.encKeySize))
+	h = binary.BigEndian.AppendUint32(h, aes.BlockSize)
+	h = binary.BigEndian.AppendUint32(h, uint32(macSize))
+	h = binary.BigEndian.AppendUint32(h, uint32(macSize))
+	h = append(h, empty...)
+	k.contextHeader = append(h, createMac(hmac.New(k.hashFunc, tempKeys[k.encKeySize:]), nil)...)
+	return k, nil
+}
+
+// encrypt encrypts a plaintext as CbcAuthenticatedEncryptor does, using aad
+// as the label of the key derivation.
+func (k *dataProtectionKey) encrypt(plaintext, aad, keyModifier, iv []byte) ([]byte, error) {
+	macSize := k.hashFunc().Size()
+	subkeys := sp800108(k.MasterKey, aad, append(k.contextHeader[:len(k.contextHeader):len(k.contextHeader)], keyModifier...), k.encKeySize+macSize)
+	block, err := aes.NewCipher(subkeys[:k.encKeySize])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := pkcs7Pad(plaintext, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	out := append(append(keyModifier[:len(keyModifier):len(keyModifier)], iv...), ciphertext...)
+	return append(out, createMac(hmac.New(k.hashFunc, subkeys[k.encKeySize:]), out[len(keyModifier):])...), nil
+}
+
+// decrypt decrypts the output of encrypt.
+func (k *dataProtectionKey) decrypt(b, aad []byte) ([]byte, error) {
+	macSize := k.hashFunc().Size()
+	if len(b) < dataProtectionKeyModifierSize+2*aes.BlockSize+macSize {
+		return nil, ErrMacInvalid
+	}
+	keyModifier := b[:dataProtectionKeyModifierSize]
+	body, mac := b[dataProtectionKeyModifierSize:len(b)-macSize], b[len(b)-macSize:]
+	subkeys := sp800108(k.MasterKey, aad, append(k.contextHeader[:len(k.contextHeader):len(k.contextHeader)], keyModifier...), k.encKeySize+macSize)
+	if err := verifyMac(hmac.New(k.hashFunc, subkeys[k.encKeySize:]), body, mac); err != nil {
+		return nil, err
+	}
+	iv, ciphertext := body[:aes.BlockSize], body[aes.BlockSize:]
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrMacInvalid
+	}
+	block, err := aes.NewCipher(subkeys[:k.encKeySize])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	return pkcs7Unpad(plaintext, aes.BlockSize)
+}
+
+// DataProtection encodes and decodes values in the format of ASP.NET Core
+// Data Protection, as used by cookie authentication and antiforgery.
+//
+// Values that are a []byte, a string or implement Coder are protected as
+// is; other values are encoded as JSON. Note that authentication tickets
+// use a binary format of their own: decode them into a []byte or a Coder.
+//
+// The cookie name selects the purposes that payloads are bound to, as set
+// by Purposes. Protected payloads carry no timestamp, so expiry is left to
+// the cookie attributes.
+type DataProtection struct {
+	keys        map[[16]byte]*dataProtectionKey
+	application string
+	purposes    map[string][]string
+	maxLength   int
+	err         error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewDataProtection returns a new DataProtection codec.
+//
+// Values are protected with the default key of the key ring: the most
+// recently activated key that is neither expired nor revoked. Any key that
+// is not revoked can unprotect values. Use LoadDataProtectionKeyRing to
+// load the keys.
+func NewDataProtection(keys ...*DataProtectionKey) *DataProtection {
+	d := &DataProtection{
+		keys:      make(map[[16]byte]*dataProtectionKey),
+		purposes:  make(map[string][]string),
+		maxLength: 4096,
+	}
+	for _, key := range keys {
+		k, err := newDataProtectionKey(key)
+		if err != nil {
+			d.err = err
+			return d
+		}
+		d.keys[k.id] = k
+	}
+	if len(d.keys) == 0 {
+		d.err = ErrHashKeyNotSet
+	}
+	return d
+}
+
+// ApplicationName sets the application discriminator, the root purpose
+// shared by all the protectors of an application. It is set with
+// SetApplicationName in .NET and defaults there to the content root path
+// of the application.
+//
+// Default is "" (no discriminator).
+func (d *DataProtection) ApplicationName(name string) *DataProtection {
+	d.application = name
+	return d
+}
+
+// Purposes sets the chain of purposes used to protect the cookie with the
+// given name. Use DataProtectionCookiePurposes for authentication cookies.
+//
+// Default is the cookie name as the only purpose.
+func (d *DataProtection) Purposes(name string, purposes ...string) *DataProtection {
+	d.purposes[name] = purposes
+	return d
+}
+
+// MaxLength restricts the maximum length, in bytes, for the cookie value.
+//
+// Default is 4096.
+func (d *DataProtection) MaxLength(value int) *DataProtection {
+	d.maxLength = value
+	return d
+}
+
+// Encode protects a value as IDataProtector.Protect does and encodes it
+// with base64url, as done for cookies.
+func (d *DataProtection) Encode(name string, value interface{}) (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	plaintext, err := marshalBytes(value)
+	if err == ErrUnsupportedValue {
+		plaintext, err = json.Marshal(value)
+	}
+	if err != nil {
+		return "", err
+	}
+	key := d.defaultKey()
+	if key == nil {
+		return "", ErrDataProtectionKey
+	}
+	keyModifier, iv := GenerateRandomKey(dataProtectionKeyModifierSize), GenerateRandomKey(aes.BlockSize)
+	if keyModifier == nil || iv == nil {
+		return "", errors.New("securecookie: failed to generate random iv")
+	}
+	aad := d.additionalData(key.id, name)
+	b, err := key.encrypt(plaintext, aad, keyModifier, iv)
+	if err != nil {
+		return "", err
+	}
+	out := base64.RawURLEncoding.EncodeToString(append(aad[:20:20], b...))
+	if d.maxLength != 0 && len(out) > d.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode unprotects a value as IDataProtector.Unprotect does.
+//
+// The dst argument must be a pointer.
+func (d *DataProtection) Decode(name, value string, dst interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.maxLength != 0 && len(value) > d.maxLength {
+		return ErrTooLong
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return err
+	}
+	if len(b) < 20 || binary.BigEndian.Uint32(b) != dataProtectionMagic {
+		return ErrDataProtectionHeader
+	}
+	var id [16]byte
+	copy(id[:], b[4:20])
+	key, ok := d.keys[id]
+	if !ok || key.Revoked {
+		return ErrDataProtectionKey
+	}
+	plaintext, err := key.decrypt(b[20:], d.additionalData(id, name))
+	if err != nil {
+		return err
+	}
+	if err = unmarshalBytes(plaintext, dst); err != ErrUnsupportedValue {
+		return err
+	}
+	return json.Unmarshal(plaintext, dst)
+}
+
+// defaultKey returns the key used to protect values, as selected by the
+// default key resolver, or nil if there is none.
+func (d *DataProtection) defaultKey() *dataProtectionKey {
+	t := time.Unix(now(d.timeFunc), 0)
+	var found *dataProtectionKey
+	for _, key := range d.keys {
+		if key.Revoked || key.Activation.After(t) || !key.Expiration.After(t) {
+			continue
+		}
+		if found == nil || key.Activation.After(found.Activation) {
+			found = key
+		}
+	}
+	return found
+}
+
+// additionalData returns the additional authenticated data of a payload:
+// the magic header, the key ID and the chain of purposes.
+func (d *DataProtection) additionalData(id [16]byte, name string) []byte {
+	purposes, ok := d.purposes[name]
+	if !ok {
+		purposes = []string{name}
+	}
+	if d.application != "" {
+		purposes = append([]string{d.application}, purposes...)
+	}
+	b := binary.BigEndian.AppendUint32(nil, dataProtectionMagic)
+	b = append(b, id[:]...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(purposes)))
+	for _, purpose := range purposes {
+		// Strings are prefixed with their length as done by BinaryWriter.
+		b = binary.AppendUvarint(b, uint64(len(purpose)))
+		b = append(b, purpose...)
+	}
+	return b
+}
+
+// sp800108 derives n bytes from a key derivation key with the NIST SP800-108
+// KDF in counter mode, using HMACSHA512 as the PRF.
+func sp800108(kdk, label, context []byte, n int) []byte {
+	var out []byte
+	mac := hmac.New(sha512.New, kdk)
+	for i := uint32(1); len(out) < n; i++ {
+		mac.Reset()
+		mac.Write(binary.BigEndian.AppendUint32(nil, i))
+		mac.Write(label)
+		mac.Write([]byte{0})
+		mac.Write(context)
+		mac.Write(binary.BigEndian.AppendUint32(nil, uint32(n*8)))
+		out = mac.Sum(out)
+	}
+	return out[:n]
+}
+
+// parseGUID parses a GUID into the byte order of .NET's Guid.ToByteArray,
+// which stores its first three fields in little-endian order.
+func parseGUID(s string) ([16]byte, error) {
+	var id [16]byte
+	s = strings.Trim(s, "{}")
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != 16 || len(s) != 36 {
+		return id, errors.New("securecookie: invalid GUID " + s)
+	}
+	copy(id[:], b)
+	for _, r := range [][2]int{{0, 4}, {4, 6}, {6, 8}} {
+		field := id[r[0]:r[1]]
+		for i, j := 0, len(field)-1; i < j; i, j = i+1, j-1 {
+			field[i], field[j] = field[j], field[i]
+		}
+	}
+	return id, nil
+}
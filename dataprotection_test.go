@@ -0,0 +1,129 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const dataProtectionKeyXMLTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<key id="%s" version="1">
+  <creationDate>%s</creationDate>
+  <activationDate>%s</activationDate>
+  <expirationDate>2100-01-01T00:00:00Z</expirationDate>
+  <descriptor deserializerType="Microsoft.AspNetCore.DataProtection.AuthenticatedEncryption.ConfigurationModel.AuthenticatedEncryptorDescriptorDeserializer, Microsoft.AspNetCore.DataProtection">
+    <descriptor>
+      <encryption algorithm="AES_256_CBC" />
+      <validation algorithm="HMACSHA256" />
+      <masterKey p4:requiresEncryption="true" xmlns:p4="http://schemas.asp.net/2015/03/dataProtection">
+        <!-- Warning: the key below is in an unencrypted form. -->
+        <value>%s</value>
+      </masterKey>
+    </descriptor>
+  </descriptor>
+</key>`
+
+func writeDataProtectionKey(t *testing.T, dir, id, created string, masterKey []byte) {
+	data := []byte(fmt.Sprintf(dataProtectionKeyXMLTemplate, id, created, created, base64.StdEncoding.EncodeToString(masterKey)))
+	if err := os.WriteFile(filepath.Join(dir, "key-"+id+".xml"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test vector of CbcAuthenticatedEncryptorTests.Encrypt_KnownKey in ASP.NET
+// Core, without its pre and post buffers.
+func TestDataProtectionEncryptor(t *testing.T) {
+	k, err := newDataProtectionKey(&DataProtectionKey{
+		ID:        "80732141-ec8f-4b80-af9c-c4d2d1ff8901",
+		MasterKey: []byte("master key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyModifier := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	iv := []byte{16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31}
+	aad := []byte{6, 5, 4, 3}
+	b, err := k.encrypt([]byte{2, 3, 4}, aad, keyModifier, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh+36j4yWJOjBgOJxmYDYwhLnYqFxw+9mNh/cudyPrWmJmw4d/dmGaLJLLut2udiAAA="
+	if got := base64.StdEncoding.EncodeToString(b); got != expected {
+		t.Errorf("Expected %v, got %v.", expected, got)
+	}
+	plaintext, err := k.decrypt(b, aad)
+	if err != nil || string(plaintext) != "\x02\x03\x04" {
+		t.Errorf("Unexpected value %v (%v)", plaintext, err)
+	}
+	if _, err = k.decrypt(b, []byte{6, 5, 4}); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+	id := []byte{0x41, 0x21, 0x73, 0x80, 0x8f, 0xec, 0x80, 0x4b, 0xaf, 0x9c, 0xc4, 0xd2, 0xd1, 0xff, 0x89, 0x01}
+	if string(k.id[:]) != string(id) {
+		t.Errorf("Expected %x, got %x.", id, k.id)
+	}
+}
+
+func TestDataProtectionKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	writeDataProtectionKey(t, dir, "80732141-ec8f-4b80-af9c-c4d2d1ff8901", "2020-01-01T00:00:00Z", []byte("old master key"))
+	writeDataProtectionKey(t, dir, "b5b8d6d4-4a0f-4c5b-9d0a-3f1b2c3d4e5f", "2020-06-01T00:00:00.1234567Z", []byte("new master key"))
+	keys, err := LoadDataProtectionKeyRing(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d.", len(keys))
+	}
+
+	ts := func() int64 { return 1600000000 }
+	old := NewDataProtection(keys[0])
+	old.timeFunc = ts
+	d := NewDataProtection(keys...).ApplicationName("/app").
+		Purposes(".AspNetCore.Cookies", DataProtectionCookiePurposes("Cookies")...)
+	d.timeFunc = ts
+	encoded, err := d.Encode(".AspNetCore.Cookies", []byte("ticket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ticket []byte
+	if err = d.Decode(".AspNetCore.Cookies", encoded, &ticket); err != nil || string(ticket) != "ticket" {
+		t.Errorf("Unexpected value %q (%v)", ticket, err)
+	}
+	if err = d.Decode("other", encoded, &ticket); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+	// The newest key protects values; older keys can only unprotect them.
+	if err = old.Decode(".AspNetCore.Cookies", encoded, &ticket); err != ErrDataProtectionKey {
+		t.Errorf("Expected %v, got %v.", ErrDataProtectionKey, err)
+	}
+	oldValue, err := old.Encode("session", map[string]int{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst map[string]int
+	if err = d.ApplicationName("").Decode("session", oldValue, &dst); err != nil || dst["id"] != 7 {
+		t.Errorf("Unexpected value %v (%v)", dst, err)
+	}
+
+	revocation := `<revocation version="1"><revocationDate>2020-03-01T00:00:00Z</revocationDate><key id="*" /><reason>compromised</reason></revocation>`
+	if err = os.WriteFile(filepath.Join(dir, "revocation-1.xml"), []byte(revocation), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err = LoadDataProtectionKeyRing(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewDataProtection(keys...).Decode("session", oldValue, &dst); err != ErrDataProtectionKey {
+		t.Errorf("Expected %v, got %v.", ErrDataProtectionKey, err)
+	}
+	if err = d.Decode("session", "AAAA"+oldValue[4:], &dst); err != ErrDataProtectionHeader {
+		t.Errorf("Expected %v, got %v.", ErrDataProtectionHeader, err)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"math"
+	"strings"
+)
+
+// Salts used by Django for signed values.
+const (
+	DjangoDefaultSalt = "django.core.signing"
+	DjangoSessionSalt = "django.contrib.sessions.backends.signed_cookies"
+)
+
+// Django encodes and decodes values in the format of Django's
+// signing.dumps and signing.loads, as used by the signed_cookies session
+// backend.
+//
+// Values are encoded as JSON, unless they implement Coder. The timestamp
+// set by TimestampSigner is checked against MaxAge and MinAge. The cookie
+// name is not part of the value. For key rotation, as done by Django's
+// SECRET_KEY_FALLBACKS, pass several codecs to EncodeMulti and DecodeMulti.
+type Django struct {
+	secretKey        []byte
+	salt             string
+	hashFunc         func() hash.Hash
+	compress         bool
+	maxLength        int
+	maxInflateLength int
+	maxAge           int64
+	minAge           int64
+	err              error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewDjango returns a new Django codec.
+//
+// secretKey is the SECRET_KEY of the Django application.
+func NewDjango(secretKey []byte) *Django {
+	d := &Django{
+		secretKey:        secretKey,
+		salt:             DjangoDefaultSalt,
+		hashFunc:         sha256.New,
+		maxAge:           86400 * 30,
+		maxLength:        4096,
+		maxInflateLength: 65536,
+	}
+	if len(secretKey) == 0 {
+		d.err = ErrHashKeyNotSet
+	}
+	return d
+}
+
+// Salt sets the salt used as a namespace for signatures. Use
+// DjangoSessionSalt for the signed_cookies session backend.
+//
+// Default is DjangoDefaultSalt.
+func (d *Django) Salt(salt string) *Django {
+	d.salt = salt
+	return d
+}
+
+// HashFunc sets the hash function used by the salted HMAC. Django versions
+// before 3.1 use crypto/sha1.New.
+//
+// Default is crypto/sha256.New.
+func (d *Django) HashFunc(f func() hash.Hash) *Django {
+	d.hashFunc = f
+	return d
+}
+
+// Compress enables zlib compression of encoded values when it makes them
+// shorter. Compressed values are always accepted by Decode.
+//
+// Default is false.
+func (d *Django) Compress(value bool) *Django {
+	d.compress = value
+	return d
+}
+
+// MaxInflateLength restricts the maximum length, in bytes, of a
+// decompressed value.
+//
+// Default is 65536.
+func (d *Django) MaxInflateLength(value int) *Django {
+	d.maxInflateLength = value
+	return d
+}
+
+// MaxLength restricts the maximum length, in bytes, for the signed value.
+//
+// Default is 4096.
+func (d *Django) MaxLength(value int) *Django {
+	d.maxLength = value
+	return d
+}
+
+// MaxAge restricts the maximum age, in seconds, for the signed value. It
+// plays the role of the max_age argument of signing.loads.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (d *Django) MaxAge(value int) *Django {
+	d.maxAge = int64(value)
+	return d
+}
+
+// MinAge restricts the minimum age, in seconds, for the signed value.
+//
+// Default is 0 (no restriction).
+func (d *Django) MinAge(value int) *Django {
+	d.minAge = int64(value)
+	return d
+}
+
+// Encode encodes a value as signing.dumps does.
+//
+// The name argument is ignored.
+func (d *Django) Encode(name string, value interface{}) (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	b, err := marshalJSON(value)
+	if err != nil {
+		return "", err
+	}
+	prefix := ""
+	if d.compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		if _, err = w.Write(b); err != nil {
+			return "", err
+		}
+		if err = w.Close(); err != nil {
+			return "", err
+		}
+		if buf.Len() < len(b)-1 {
+			b, prefix = buf.Bytes(), "."
+		}
+	}
+	out := prefix + base64.RawURLEncoding.EncodeToString(b) + ":" + formatBase62(now(d.timeFunc))
+	out += ":" + d.signature(out)
+	if d.maxLength != 0 && len(out) > d.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a value as signing.loads does.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (d *Django) Decode(name, value string, dst interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.maxLength != 0 && len(value) > d.maxLength {
+		return ErrTooLong
+	}
+	i := strings.LastIndexByte(value, ':')
+	if i == -1 {
+		return ErrMacInvalid
+	}
+	signed, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(d.signature(signed))) {
+		return ErrMacInvalid
+	}
+	i = strings.LastIndexByte(signed, ':')
+	if i == -1 {
+		return ErrTimeInvalid
+	}
+	t1, err := parseBase62(signed[i+1:])
+	if err != nil {
+		return ErrTimeInvalid
+	}
+	if err = checkAge(t1, now(d.timeFunc), d.minAge, d.maxAge); err != nil {
+		return err
+	}
+	data := signed[:i]
+	compressed := strings.HasPrefix(data, ".")
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(data, "."))
+	if err != nil {
+		return err
+	}
+	if compressed {
+		r, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		if b, err = readAll(r, d.maxInflateLength); err != nil {
+			return err
+		}
+	}
+	return unmarshalJSON(b, dst)
+}
+
+// signature returns the signature of a value, as computed by Signer.
+func (d *Django) signature(value string) string {
+	// salted_hmac derives the HMAC key by hashing the salt and the secret.
+	h := d.hashFunc()
+	h.Write([]byte(d.salt + "signer"))
+	h.Write(d.secretKey)
+	mac := createMac(hmac.New(d.hashFunc, h.Sum(nil)), []byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac)
+}
+
+// formatBase62 returns the base62 representation of a non-negative integer.
+func formatBase62(n int64) string {
+	if n <= 0 {
+		return "0"
+	}
+	var buf [11]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// parseBase62 parses a base62 representation of a non-negative integer.
+func parseBase62(s string) (int64, error) {
+	if s == "" {
+		return 0, ErrBase62
+	}
+	var n int64
+	for i := 0; i < len(s); i++ {
+		d := base62Index(s[i])
+		if d < 0 || n > (math.MaxInt64-int64(d))/62 {
+			return 0, ErrBase62
+		}
+		n = n*62 + int64(d)
+	}
+	return n, nil
+}
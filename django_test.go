@@ -0,0 +1,88 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/sha1"
+	"strings"
+	"testing"
+)
+
+// Values in the format of signing.dumps, signed with the SECRET_KEY
+// "django-insecure-key" at timestamp 1600000000.
+const (
+	djangoValue      = "eyJmb28iOiJiYXIiLCJuIjoxfQ:1kHR5c:B7nS_TwJQP4xVcgb-OcPjkBcDjmZHYeTvTaGmCSodkg"
+	djangoCompressed = ".eJyrVopPLC3JiC8tTi2Kz0xRslIyVNJRSkksSQQyK4YJUKoFAGUgZ48:1kHR5c:NPQWEXTGNJWLrI_6yXKC1gPj8HvsRiPB6bi74yrZp8Q"
+)
+
+func TestDjangoVectors(t *testing.T) {
+	d := NewDjango([]byte("django-insecure-key"))
+	d.timeFunc = func() int64 { return 1600000000 }
+	got, err := d.Encode("", map[string]interface{}{"foo": "bar", "n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != djangoValue {
+		t.Errorf("Expected %v, got %v.", djangoValue, got)
+	}
+	var dst map[string]interface{}
+	if err = d.Decode("", djangoValue, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst["foo"] != "bar" || dst["n"] != float64(1) {
+		t.Errorf("Unexpected value %v", dst)
+	}
+	s := NewDjango([]byte("django-insecure-key")).Salt(DjangoSessionSalt)
+	s.timeFunc = d.timeFunc
+	var session map[string]string
+	if err = s.Decode("", djangoCompressed, &session); err != nil {
+		t.Fatal(err)
+	}
+	if session["_auth_user_id"] != "1" || session["data"] != strings.Repeat("x", 200) {
+		t.Errorf("Unexpected value %v", session)
+	}
+	if err = d.Decode("", djangoCompressed, &session); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+	if err = s.MaxInflateLength(100).Decode("", djangoCompressed, &session); err != ErrTooLong {
+		t.Errorf("Expected %v, got %v.", ErrTooLong, err)
+	}
+}
+
+func TestDjangoAge(t *testing.T) {
+	var ts int64 = 1600000000
+	d := NewDjango([]byte("secret")).HashFunc(sha1.New).Compress(true).MaxAge(60).MinAge(5)
+	d.timeFunc = func() int64 { return ts }
+	encoded, err := d.Encode("", strings.Repeat("abc", 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, ".") {
+		t.Errorf("Expected a compressed value, got %v.", encoded)
+	}
+	var dst string
+	for _, test := range []struct {
+		Now int64
+		Err error
+	}{{ts, ErrTooNew}, {ts + 30, nil}, {ts + 61, ErrExpired}} {
+		ts = test.Now
+		if err = d.Decode("", encoded, &dst); err != test.Err {
+			t.Errorf("At %d: expected %v, got %v", test.Now, test.Err, err)
+		}
+	}
+}
+
+func TestBase62Integer(t *testing.T) {
+	for _, n := range []int64{0, 1, 61, 62, 1600000000, 1<<62 - 1} {
+		got, err := parseBase62(formatBase62(n))
+		if err != nil || got != n {
+			t.Errorf("Expected %d, got %d (%v).", n, got, err)
+		}
+	}
+	if formatBase62(1600000000) != "1kHR5c" {
+		t.Errorf("Expected %q, got %q.", "1kHR5c", formatBase62(1600000000))
+	}
+}
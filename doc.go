@@ -66,6 +66,17 @@ value:
 
 We stored a map[string]string, but secure cookies can hold any value that
 can be encoded using encoding/gob. To store custom types, they must be
-registered first using cookie.Register(<value>).
+registered first using cookie.Register(<value>), in the same order by every
+instance that decodes the cookies, unless the stateless gob format of
+NewStatelessGobSerializer() is used. Another format can be
+chosen with SetSerializer, such as JSON with NewJSONSerializer(); values
+that implement Coder bypass the serializer, as can values that implement
+encoding.BinaryMarshaler, encoding.TextMarshaler or the methods generated
+for protocol buffers; see SecureCookie.Detect. The securecookie-gen command, in cmd/securecookie-gen,
+writes Coder implementations for struct types, which avoid reflection.
+
+Data too large for a cookie, such as exported files, can be protected with
+the same keys using SecureCookie.NewSealWriter and SecureCookie.NewOpenReader,
+which authenticate and encrypt it in chunks.
 */
 package securecookie
@@ -0,0 +1,118 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Explanation is the breakdown of the length, in bytes, of an encoded cookie
+// value. The parts of the value add up to Value.
+type Explanation struct {
+	// Serialized is the length of the serialized value.
+	Serialized int
+	// Header is the length of the schema version and registry fingerprint,
+	// if they are stored.
+	Header int
+	// IV is the length of the initialization vector, if values are
+	// encrypted.
+	IV int
+	// Timestamp is the length of the timestamp.
+	Timestamp int
+	// Separators is the length of the separators between the timestamp, the
+	// encrypted value and the MAC.
+	Separators int
+	// MAC is the length of the message authentication code.
+	MAC int
+	// Base64 is the expansion caused by the base64 encoding of the encrypted
+	// value, and of the whole value.
+	Base64 int
+	// Value is the length of the encoded value.
+	Value int
+	// MaxLength is the maximum length of the value; see
+	// SecureCookie.MaxLength.
+	MaxLength int
+	// Cookie is the length of a Set-Cookie header value with the cookie name,
+	// the value, and typical attributes: Path=/, Expires and Max-Age for the
+	// maximum age, HttpOnly, Secure and SameSite=Lax.
+	Cookie int
+}
+
+// String returns a report of the breakdown.
+func (e *Explanation) String() string {
+	var b strings.Builder
+	for _, part := range []struct {
+		name   string
+		length int
+	}{
+		{"serialized", e.Serialized},
+		{"header", e.Header},
+		{"iv", e.IV},
+		{"timestamp", e.Timestamp},
+		{"separators", e.Separators},
+		{"mac", e.MAC},
+		{"base64", e.Base64},
+	} {
+		fmt.Fprintf(&b, "%-10s %5d\n", part.name, part.length)
+	}
+	fmt.Fprintf(&b, "value      %5d", e.Value)
+	if e.MaxLength != 0 {
+		fmt.Fprintf(&b, " (max %d)", e.MaxLength)
+	}
+	fmt.Fprintf(&b, "\ncookie     %5d\n", e.Cookie)
+	return b.String()
+}
+
+// Explain returns the breakdown of the length of the value that Encode would
+// return, without encrypting or signing it.
+//
+// The value is serialized as Encode does: with the default GobSerializer,
+// values of unregistered types include their type descriptors. No random
+// bytes are read. If the value would be too long, the breakdown is returned
+// along with ErrTooLong.
+func (s *SecureCookie) Explain(name string, value interface{}) (*Explanation, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.hashKey == nil {
+		s.err = ErrHashKeyNotSet
+		return nil, s.err
+	}
+	b, err := s.marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{
+		Serialized: len(b),
+		Header:     len(s.appendVersion(nil)),
+		Timestamp:  len(strconv.FormatInt(s.timestamp(), 10)),
+		Separators: 2,
+		MAC:        s.hashFunc().Size(),
+		MaxLength:  s.maxLength,
+	}
+	if s.embedFP {
+		e.Header += 4
+	}
+	if s.block != nil {
+		e.IV = s.block.BlockSize()
+	}
+	raw := e.Serialized + e.Header + e.IV
+	encoded := base64.URLEncoding.EncodedLen(raw)
+	signed := e.Timestamp + e.Separators + encoded + e.MAC
+	e.Value = base64.URLEncoding.EncodedLen(signed)
+	e.Base64 = encoded - raw + e.Value - signed
+	e.Cookie = len(name) + len("=") + e.Value + len("; Path=/; HttpOnly; Secure; SameSite=Lax")
+	if s.maxAge > 0 {
+		e.Cookie += len("; Expires=Mon, 02 Jan 2006 15:04:05 GMT; Max-Age=") + len(strconv.FormatInt(s.maxAge, 10))
+	}
+	if s.maxLength != 0 && e.Value > s.maxLength {
+		return e, ErrTooLong
+	}
+	return e, nil
+}
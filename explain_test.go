@@ -0,0 +1,66 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/sha1"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	for _, s := range []*SecureCookie{
+		New([]byte("12345"), nil),
+		New([]byte("12345"), []byte("1234567890123456")),
+		New([]byte("12345"), []byte("1234567890123456")).HashFunc(sha1.New).EmbedFingerprint(true),
+		New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(300).MaxAge(0),
+	} {
+		s.timeFunc = func() int64 { return 1600000000 }
+		value := map[string]string{"foo": "bar", "baz": strings.Repeat("x", 100)}
+		// Registered, so that the value leaves out the gob type
+		// descriptors.
+		s.Register(value)
+		e, err := s.Explain("sid", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := s.Encode("sid", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Value != len(encoded) {
+			t.Errorf("Expected %v, got %v.", len(encoded), e.Value)
+		}
+		if sum := e.Serialized + e.Header + e.IV + e.Timestamp + e.Separators + e.MAC + e.Base64; sum != e.Value {
+			t.Errorf("Expected %v, got %v.", e.Value, sum)
+		}
+	}
+
+	s := New([]byte("12345"), []byte("1234567890123456")).MaxLength(100)
+	s.timeFunc = func() int64 { return 1600000000 }
+	e, err := s.Explain("sid", strings.Repeat("x", 200))
+	if err != ErrTooLong || e == nil {
+		t.Fatalf("Expected %v, got %v.", ErrTooLong, err)
+	}
+	expected := &Explanation{
+		Serialized: 206,
+		IV:         16,
+		Timestamp:  10,
+		Separators: 2,
+		MAC:        32,
+		Base64:     190,
+		Value:      456,
+		MaxLength:  100,
+		Cookie:     456 + 4 + 40 + 56,
+	}
+	if *e != *expected {
+		t.Errorf("Expected %+v, got %+v.", expected, e)
+	}
+	report := e.String()
+	if !strings.Contains(report, "value        456 (max 100)\n") {
+		t.Errorf("Unexpected report:\n%s", report)
+	}
+}
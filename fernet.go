@@ -0,0 +1,227 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	fernetVersion   = 0x80
+	fernetKeySize   = 32
+	fernetMaxSkew   = 60
+	fernetHeaderLen = 1 + 8 + aes.BlockSize
+	fernetMinLen    = fernetHeaderLen + aes.BlockSize + sha256.Size
+)
+
+var (
+	ErrFernetKey     = errors.New("securecookie: fernet key must be 32 bytes")
+	ErrFernetVersion = errors.New("securecookie: unknown fernet version")
+
+	errPadding = errors.New("securecookie: the value could not be decrypted")
+)
+
+// Fernet encodes and decodes values as Fernet tokens.
+//
+// The format is described at https://github.com/fernet/spec. Tokens carry
+// opaque bytes, so values must be a []byte, a string or implement Coder.
+// The cookie name is not part of the token.
+//
+// For key rotation in the style of MultiFernet, pass several Fernet codecs
+// to EncodeMulti and DecodeMulti, newest key first.
+type Fernet struct {
+	signKey   []byte
+	block     cipher.Block
+	maxLength int
+	maxAge    int64
+	minAge    int64
+	err       error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewFernet returns a new Fernet codec.
+//
+// key must be 32 bytes: the first half is the signing key and the second
+// half the AES-128 encryption key. Use ParseFernetKey to decode the base64
+// keys generated by other implementations.
+func NewFernet(key []byte) *Fernet {
+	f := &Fernet{
+		maxAge:    86400 * 30,
+		maxLength: 4096,
+	}
+	if len(key) != fernetKeySize {
+		f.err = ErrFernetKey
+		return f
+	}
+	f.signKey = key[:16]
+	f.block, f.err = aes.NewCipher(key[16:])
+	return f
+}
+
+// ParseFernetKey decodes a base64url encoded Fernet key.
+func ParseFernetKey(key string) ([]byte, error) {
+	b, err := base64.URLEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != fernetKeySize {
+		return nil, ErrFernetKey
+	}
+	return b, nil
+}
+
+// FernetCodecs returns a slice of Fernet codecs, one for each key.
+//
+// It is a convenience function to create a list of codecs for key rotation.
+func FernetCodecs(keys ...[]byte) []Codec {
+	codecs := make([]Codec, len(keys))
+	for i, key := range keys {
+		codecs[i] = NewFernet(key)
+	}
+	return codecs
+}
+
+// MaxLength restricts the maximum length, in bytes, for the token.
+//
+// Default is 4096.
+func (f *Fernet) MaxLength(value int) *Fernet {
+	f.maxLength = value
+	return f
+}
+
+// MaxAge restricts the maximum age, in seconds, for the token. It plays the
+// role of the ttl argument in other implementations.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (f *Fernet) MaxAge(value int) *Fernet {
+	f.maxAge = int64(value)
+	return f
+}
+
+// MinAge restricts the minimum age, in seconds, for the token.
+//
+// Default is 0 (no restriction). Tokens more than 60 seconds in the future
+// are always rejected.
+func (f *Fernet) MinAge(value int) *Fernet {
+	f.minAge = int64(value)
+	return f
+}
+
+// Encode encodes a value as a Fernet token.
+//
+// The name argument is ignored.
+func (f *Fernet) Encode(name string, value interface{}) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	b, err := marshalBytes(value)
+	if err != nil {
+		return "", err
+	}
+	iv := GenerateRandomKey(aes.BlockSize)
+	if iv == nil {
+		return "", errors.New("securecookie: failed to generate random iv")
+	}
+	out := base64.URLEncoding.EncodeToString(f.seal(b, iv, now(f.timeFunc)))
+	if f.maxLength != 0 && len(out) > f.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a Fernet token.
+//
+// The name argument is ignored. The dst argument must be a *[]byte,
+// a *string or implement Coder.
+func (f *Fernet) Decode(name, value string, dst interface{}) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.maxLength != 0 && len(value) > f.maxLength {
+		return ErrTooLong
+	}
+	b, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	b, err = f.open(b, now(f.timeFunc))
+	if err != nil {
+		return err
+	}
+	return unmarshalBytes(b, dst)
+}
+
+// seal builds a token for the given plaintext, iv and timestamp.
+func (f *Fernet) seal(plaintext, iv []byte, ts int64) []byte {
+	padded := pkcs7Pad(plaintext, aes.BlockSize)
+	b := make([]byte, fernetHeaderLen+len(padded), fernetHeaderLen+len(padded)+sha256.Size)
+	b[0] = fernetVersion
+	binary.BigEndian.PutUint64(b[1:9], uint64(ts))
+	copy(b[9:fernetHeaderLen], iv)
+	cipher.NewCBCEncrypter(f.block, iv).CryptBlocks(b[fernetHeaderLen:], padded)
+	return append(b, createMac(hmac.New(sha256.New, f.signKey), b)...)
+}
+
+// open verifies a token and returns its plaintext.
+func (f *Fernet) open(b []byte, t2 int64) ([]byte, error) {
+	if len(b) < fernetMinLen || (len(b)-fernetMinLen)%aes.BlockSize != 0 {
+		return nil, ErrMacInvalid
+	}
+	if b[0] != fernetVersion {
+		return nil, ErrFernetVersion
+	}
+	msg, mac := b[:len(b)-sha256.Size], b[len(b)-sha256.Size:]
+	if err := verifyMac(hmac.New(sha256.New, f.signKey), msg, mac); err != nil {
+		return nil, err
+	}
+	t1 := int64(binary.BigEndian.Uint64(b[1:9]))
+	if t1 > t2+fernetMaxSkew {
+		return nil, ErrTooNew
+	}
+	if err := checkAge(t1, t2, f.minAge, f.maxAge); err != nil {
+		return nil, err
+	}
+	iv := msg[9:fernetHeaderLen]
+	plaintext := make([]byte, len(msg)-fernetHeaderLen)
+	cipher.NewCBCDecrypter(f.block, iv).CryptBlocks(plaintext, msg[fernetHeaderLen:])
+	return pkcs7Unpad(plaintext, aes.BlockSize)
+}
+
+// pkcs7Pad appends PKCS #7 padding to a value.
+func pkcs7Pad(value []byte, size int) []byte {
+	n := size - len(value)%size
+	out := make([]byte, len(value)+n)
+	copy(out, value)
+	for i := len(value); i < len(out); i++ {
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// pkcs7Unpad removes PKCS #7 padding from a value.
+func pkcs7Unpad(value []byte, size int) ([]byte, error) {
+	if len(value) == 0 || len(value)%size != 0 {
+		return nil, errPadding
+	}
+	n := int(value[len(value)-1])
+	if n == 0 || n > size {
+		return nil, errPadding
+	}
+	for _, c := range value[len(value)-n:] {
+		if int(c) != n {
+			return nil, errPadding
+		}
+	}
+	return value[:len(value)-n], nil
+}
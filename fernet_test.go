@@ -0,0 +1,116 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// Vectors from https://github.com/fernet/spec.
+var fernetVector = struct {
+	Token  string
+	Now    string
+	IV     []byte
+	Src    string
+	Secret string
+}{
+	Token:  "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA==",
+	Now:    "1985-10-26T01:20:00-07:00",
+	IV:     []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	Src:    "hello",
+	Secret: "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=",
+}
+
+func fernetVectorCodec(t *testing.T) (*Fernet, int64) {
+	key, err := ParseFernetKey(fernetVector.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := time.Parse(time.RFC3339, fernetVector.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFernet(key), ts.Unix()
+}
+
+func TestFernetGenerate(t *testing.T) {
+	f, ts := fernetVectorCodec(t)
+	got := base64.URLEncoding.EncodeToString(f.seal([]byte(fernetVector.Src), fernetVector.IV, ts))
+	if got != fernetVector.Token {
+		t.Errorf("Expected %v, got %v.", fernetVector.Token, got)
+	}
+}
+
+func TestFernetVerify(t *testing.T) {
+	f, ts := fernetVectorCodec(t)
+	f.MaxAge(60)
+	f.timeFunc = func() int64 { return ts + 1 }
+	var dst string
+	if err := f.Decode("", fernetVector.Token, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst != fernetVector.Src {
+		t.Errorf("Expected %v, got %v.", fernetVector.Src, dst)
+	}
+}
+
+func TestFernetInvalid(t *testing.T) {
+	f, ts := fernetVectorCodec(t)
+	token, _ := base64.URLEncoding.DecodeString(fernetVector.Token)
+	tamper := func(i int) string {
+		b := append([]byte(nil), token...)
+		b[i] ^= 1
+		return base64.URLEncoding.EncodeToString(b)
+	}
+	tests := []struct {
+		Name  string
+		Token string
+		Now   int64
+		Err   error
+	}{
+		{"incorrect mac", tamper(len(token) - 1), ts, ErrMacInvalid},
+		{"modified ciphertext", tamper(30), ts, ErrMacInvalid},
+		{"too short", base64.URLEncoding.EncodeToString(token[:40]), ts, ErrMacInvalid},
+		{"expired ttl", fernetVector.Token, ts + 90, ErrExpired},
+		{"far-future timestamp", fernetVector.Token, ts - 61, ErrTooNew},
+		{"unknown version", base64.URLEncoding.EncodeToString(append([]byte{0x81}, token[1:]...)), ts, ErrFernetVersion},
+	}
+	for _, test := range tests {
+		f.MaxAge(60)
+		f.timeFunc = func() int64 { return test.Now }
+		var dst string
+		if err := f.Decode("", test.Token, &dst); err != test.Err {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Err, err)
+		}
+	}
+	var dst string
+	if err := f.Decode("", "%%%", &dst); err == nil {
+		t.Error("invalid base64: expected failure decoding")
+	}
+}
+
+func TestFernetRotation(t *testing.T) {
+	oldKey, newKey := GenerateRandomKey(32), GenerateRandomKey(32)
+	encoded, err := NewFernet(oldKey).Encode("", []byte("rotate me"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst []byte
+	if err = DecodeMulti("", encoded, &dst, FernetCodecs(newKey, oldKey)...); err != nil {
+		t.Fatal(err)
+	}
+	if string(dst) != "rotate me" {
+		t.Errorf("Expected %q, got %q.", "rotate me", dst)
+	}
+	if err = DecodeMulti("", encoded, &dst, FernetCodecs(newKey)...); err == nil {
+		t.Error("Expected failure decoding with the wrong key.")
+	}
+	if _, err = NewFernet([]byte("short")).Encode("", "value"); err != ErrFernetKey {
+		t.Errorf("Expected %v, got %v.", ErrFernetKey, err)
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrGobTypeMismatch = errors.New("securecookie: gob value was encoded from a different type")
+	errGobMessage      = errors.New("securecookie: invalid gob message")
+)
+
+var gobEncoderType = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+
+// StatelessGobSerializer encodes cookie values using encoding/gob, without
+// depending on the state of the encoder or the decoder.
+//
+// The gob type descriptors of each type are computed once, when the type is
+// registered or first used, and are left out of cookies. In their place, a
+// cookie identifies its type with a fingerprint of the type structure, which
+// is the same for every process that uses the same type definition. Unlike
+// with GobSerializer, instances need not register types, let alone in the
+// same order, to decode each other's cookies; Register only computes the
+// descriptors ahead of time.
+//
+// Values must be decoded into the type they were encoded from, or one with
+// the same structure; ErrGobTypeMismatch is returned otherwise. Types that
+// hold interface values, such as map[string]interface{}, need the type
+// descriptors of their dynamic values: they are encoded with a fresh
+// gob.Encoder, descriptors included.
+type StatelessGobSerializer struct {
+	types sync.Map // map[reflect.Type]*gobType
+}
+
+// NewStatelessGobSerializer returns a new StatelessGobSerializer.
+func NewStatelessGobSerializer() *StatelessGobSerializer {
+	return &StatelessGobSerializer{}
+}
+
+// Register computes the type descriptors of the type of v.
+func (g *StatelessGobSerializer) Register(v interface{}) error {
+	_, err := g.typeOf(reflect.TypeOf(v))
+	return err
+}
+
+// Serialize encodes a value using gob, without its type descriptors.
+func (g *StatelessGobSerializer) Serialize(src interface{}) ([]byte, error) {
+	t, err := g.typeOf(reflect.TypeOf(src))
+	if err != nil {
+		return nil, err
+	}
+	if t.selfDescribing {
+		var buf bytes.Buffer
+		buf.WriteByte(0)
+		if err = gob.NewEncoder(&buf).Encode(src); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.buf.Reset()
+	err = t.enc.Encode(src)
+	msg := t.buf.Bytes()
+	t.buf.Reset()
+	if err != nil {
+		return nil, err
+	}
+	return replaceGobTypeID(msg, int64(t.fingerprint))
+}
+
+// Deserialize decodes a value encoded by Serialize. The dst argument must be
+// a pointer.
+func (g *StatelessGobSerializer) Deserialize(src []byte, dst interface{}) error {
+	if len(src) > 0 && src[0] == 0 {
+		return gob.NewDecoder(bytes.NewReader(src[1:])).Decode(dst)
+	}
+	t, err := g.typeOf(reflect.TypeOf(dst))
+	if err != nil {
+		return err
+	}
+	id, err := gobTypeID(src)
+	if err != nil {
+		return err
+	}
+	if t.selfDescribing || id != int64(t.fingerprint) {
+		return ErrGobTypeMismatch
+	}
+	msg, err := replaceGobTypeID(src, t.id)
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.buf.Reset()
+	t.buf.Write(msg)
+	if err = t.dec.Decode(dst); err != nil {
+		// Start over with a fresh decoder, in case the failure left it in
+		// an inconsistent state.
+		t.buf.Reset()
+		if primeErr := t.prime(); primeErr != nil {
+			return primeErr
+		}
+	}
+	t.buf.Reset()
+	return err
+}
+
+// typeOf returns the descriptors of a type, computing them if needed.
+// Pointers are dereferenced, as gob does.
+func (g *StatelessGobSerializer) typeOf(rt reflect.Type) (*gobType, error) {
+	if rt == nil {
+		return nil, errors.New("securecookie: cannot encode a nil value with gob")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if t, ok := g.types.Load(rt); ok {
+		return t.(*gobType), nil
+	}
+	t, err := newGobType(rt)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := g.types.LoadOrStore(rt, t)
+	return actual.(*gobType), nil
+}
+
+// gobType holds an encoder and a decoder that have exchanged the type
+// descriptors of a type, so that they only handle value messages.
+type gobType struct {
+	typ            reflect.Type
+	fingerprint    uint32
+	selfDescribing bool
+	descriptors    []byte
+	id             int64 // gob type ID in this process
+	sample         []byte
+	lock           sync.Mutex
+	buf            bytes.Buffer
+	enc            *gob.Encoder
+	dec            *gob.Decoder
+}
+
+func newGobType(rt reflect.Type) (*gobType, error) {
+	desc, selfDescribing := describeGobType(rt)
+	sum := sha256.Sum256([]byte(desc))
+	t := &gobType{
+		typ: rt,
+		// Fingerprints take the place of the gob type ID of values, so they
+		// are positive and non-zero like one. The zero byte that marks
+		// self-describing values is unambiguous for another reason: the
+		// first byte of other values is the byte count of their gob
+		// message, which is never zero.
+		fingerprint:    binary.BigEndian.Uint32(sum[:]) & 0x7fffffff,
+		selfDescribing: selfDescribing,
+	}
+	if t.fingerprint == 0 {
+		t.fingerprint = 1
+	}
+	if selfDescribing {
+		return t, nil
+	}
+	// The first value sent by an encoder is preceded by the descriptors of
+	// its type, which the second value is not.
+	t.enc = gob.NewEncoder(&t.buf)
+	sample := reflect.New(rt).Interface()
+	if err := t.enc.Encode(sample); err != nil {
+		return nil, err
+	}
+	first := append([]byte(nil), t.buf.Bytes()...)
+	t.buf.Reset()
+	if err := t.enc.Encode(sample); err != nil {
+		return nil, err
+	}
+	t.sample = append([]byte(nil), t.buf.Bytes()...)
+	t.buf.Reset()
+	if !bytes.HasSuffix(first, t.sample) {
+		return nil, fmt.Errorf("securecookie: cannot compute the gob descriptors of %v", rt)
+	}
+	t.descriptors = first[:len(first)-len(t.sample)]
+	var err error
+	if t.id, err = gobTypeID(t.sample); err != nil {
+		return nil, err
+	}
+	return t, t.prime()
+}
+
+// prime creates the decoder and feeds it the type descriptors.
+func (t *gobType) prime() error {
+	t.dec = gob.NewDecoder(&t.buf)
+	t.buf.Write(t.descriptors)
+	t.buf.Write(t.sample)
+	err := t.dec.Decode(reflect.New(t.typ).Interface())
+	t.buf.Reset()
+	return err
+}
+
+// describeGobType returns a description of the structure of a type as seen
+// by gob, and whether it holds interface values. Type names are not part of
+// the description, except for types that encode themselves.
+func describeGobType(rt reflect.Type) (string, bool) {
+	var b strings.Builder
+	seen := make(map[reflect.Type]int)
+	hasInterface := false
+	var describe func(t reflect.Type)
+	describe = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if i, ok := seen[t]; ok {
+			b.WriteString("#" + strconv.Itoa(i))
+			return
+		}
+		pt := reflect.PointerTo(t)
+		if pt.Implements(gobEncoderType) || pt.Implements(binaryMarshalerType) || pt.Implements(textMarshalerType) {
+			b.WriteString("opaque(" + t.PkgPath() + "." + t.Name() + ")")
+			return
+		}
+		if t.PkgPath() != "" {
+			// Named types can be recursive.
+			seen[t] = len(seen)
+		}
+		switch t.Kind() {
+		case reflect.Interface:
+			hasInterface = true
+			b.WriteString("interface")
+		case reflect.Slice:
+			b.WriteString("[]")
+			describe(t.Elem())
+		case reflect.Array:
+			b.WriteString("[" + strconv.Itoa(t.Len()) + "]")
+			describe(t.Elem())
+		case reflect.Map:
+			b.WriteString("map[")
+			describe(t.Key())
+			b.WriteString("]")
+			describe(t.Elem())
+		case reflect.Struct:
+			b.WriteString("struct{")
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				ft := f.Type
+				for ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if !f.IsExported() || ft.Kind() == reflect.Chan || ft.Kind() == reflect.Func {
+					continue
+				}
+				b.WriteString(f.Name + " ")
+				describe(f.Type)
+				b.WriteString(";")
+			}
+			b.WriteString("}")
+		default:
+			b.WriteString(t.Kind().String())
+		}
+	}
+	describe(rt)
+	return b.String(), hasInterface
+}
+
+// Gob messages ---------------------------------------------------------------
+
+// readGobUint reads an unsigned integer as encoded by gob: values below 128
+// are stored in one byte, others as the negated byte count followed by the
+// big endian value.
+func readGobUint(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, errGobMessage
+	}
+	if b[0] < 0x80 {
+		return uint64(b[0]), 1, nil
+	}
+	n := int(-int8(b[0]))
+	if n > 8 || len(b) < 1+n {
+		return 0, 0, errGobMessage
+	}
+	return beUint(b[1 : 1+n]), 1 + n, nil
+}
+
+// appendGobUint appends an unsigned integer as encoded by gob.
+func appendGobUint(b []byte, x uint64) []byte {
+	if x < 0x80 {
+		return append(b, byte(x))
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	i := 0
+	for buf[i] == 0 {
+		i++
+	}
+	b = append(b, byte(-(8 - i)))
+	return append(b, buf[i:]...)
+}
+
+// gobTypeID returns the type ID of a value message.
+func gobTypeID(msg []byte) (int64, error) {
+	_, n, err := readGobUint(msg)
+	if err != nil {
+		return 0, err
+	}
+	u, _, err := readGobUint(msg[n:])
+	if err != nil {
+		return 0, err
+	}
+	// Signed integers store their sign in the lowest bit.
+	if u&1 != 0 {
+		return ^int64(u >> 1), nil
+	}
+	return int64(u >> 1), nil
+}
+
+// replaceGobTypeID returns a value message with its type ID replaced.
+func replaceGobTypeID(msg []byte, id int64) ([]byte, error) {
+	count, n, err := readGobUint(msg)
+	if err != nil || count != uint64(len(msg)-n) {
+		return nil, errGobMessage
+	}
+	_, m, err := readGobUint(msg[n:])
+	if err != nil {
+		return nil, err
+	}
+	var u uint64
+	if id < 0 {
+		u = uint64(^id)<<1 | 1
+	} else {
+		u = uint64(id) << 1
+	}
+	body := appendGobUint(nil, u)
+	body = append(body, msg[n+m:]...)
+	return append(appendGobUint(make([]byte, 0, len(body)+9), uint64(len(body))), body...), nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type gobTree struct {
+	Name     string
+	Children []*gobTree
+	Created  time.Time
+}
+
+func TestStatelessGobSerializer(t *testing.T) {
+	g := NewStatelessGobSerializer()
+	b, err := g.Serialize(&FooBar{42, "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The value message carries the fingerprint of the type instead of a
+	// type ID that depends on the process.
+	expected := "0d" + "fcceecfe32" + "0154010362617200"
+	if got := hex.EncodeToString(b); got != expected {
+		t.Errorf("Expected %v, got %v.", expected, got)
+	}
+
+	// Another instance decodes it without registering anything.
+	var dst FooBar
+	if err = NewStatelessGobSerializer().Deserialize(b, &dst); err != nil || dst != (FooBar{42, "bar"}) {
+		t.Errorf("Unexpected value %+v (%v)", dst, err)
+	}
+	var other struct{ Foo, Bar string }
+	if err = g.Deserialize(b, &other); err != ErrGobTypeMismatch {
+		t.Errorf("Expected %v, got %v.", ErrGobTypeMismatch, err)
+	}
+}
+
+func TestStatelessGobCookies(t *testing.T) {
+	one := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewStatelessGobSerializer())
+	two := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewStatelessGobSerializer())
+	// Registration order no longer matters.
+	one.Register(map[string]string{})
+	one.Register(&gobTree{})
+	two.Register(&gobTree{})
+
+	src := &gobTree{
+		Name:     "root",
+		Children: []*gobTree{{Name: "leaf", Created: time.Unix(1600000000, 0).UTC()}},
+	}
+	for i := 0; i < 2; i++ {
+		encoded, err := one.Encode("sid", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := &gobTree{}
+		if err = two.Decode("sid", encoded, dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, src) {
+			t.Errorf("Expected %+v, got %+v.", src, dst)
+		}
+	}
+
+	// Values holding interfaces are self-describing.
+	value := map[string]interface{}{"foo": "bar", "baz": 128}
+	encoded, err := two.Encode("sid", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make(map[string]interface{})
+	if err = one.Decode("sid", encoded, &dst); err != nil || !reflect.DeepEqual(dst, value) {
+		t.Errorf("Expected %v, got %v (%v).", value, dst, err)
+	}
+}
+
+func TestGobIntegers(t *testing.T) {
+	for _, x := range []uint64{0, 1, 127, 128, 255, 256, 1 << 40, 1<<64 - 1} {
+		b := appendGobUint(nil, x)
+		y, n, err := readGobUint(b)
+		if err != nil || y != x || n != len(b) {
+			t.Errorf("Expected %v, got %v (%v).", x, y, err)
+		}
+	}
+	msg, err := replaceGobTypeID([]byte{0x03, 0x02, 0xaa, 0xbb}, -1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := gobTypeID(msg); err != nil || id != -1000 {
+		t.Errorf("Expected %v, got %v (%v).", -1000, id, err)
+	}
+}
+
+func BenchmarkRoundtripStatelessGob(b *testing.B) {
+	cook := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewStatelessGobSerializer())
+
+	src := &FooBar{42, "bar"}
+	cook.Register(src)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	var err error
+	var val string
+	for i := 0; i < b.N; i++ {
+		val, err = cook.Encode("sid", src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = cook.Decode("sid", val, src)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
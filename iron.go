@@ -0,0 +1,235 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	ironPrefix      = "Fe26.2"
+	ironMinPassword = 32
+	ironSaltSize    = 32
+	ironMaxSkew     = 60
+)
+
+var ErrIronPassword = errors.New("securecookie: unknown iron password id")
+
+// Iron encodes and decodes values in the iron sealed format ("Fe26.2*..."),
+// as used by hapi and iron-session.
+//
+// Values are encoded as JSON, unless they implement Coder. The expiry set by
+// MaxAge is sealed with the value; expired values are rejected with a clock
+// skew of 60 seconds, as iron does. The cookie name is not part of the
+// value.
+type Iron struct {
+	id        string
+	passwords map[string][]byte
+	session   bool
+	maxLength int
+	maxAge    int64
+	err       error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewIron returns a new Iron codec.
+//
+// password is used to seal values and must be at least 32 bytes long. id is
+// the password ID stored with sealed values: iron-session uses "1" for a
+// single password, while hapi uses an empty ID. Use AddPassword to accept
+// values sealed with older passwords.
+func NewIron(id string, password []byte) *Iron {
+	i := &Iron{
+		id:        id,
+		passwords: make(map[string][]byte),
+		maxAge:    86400 * 30,
+		maxLength: 4096,
+	}
+	return i.AddPassword(id, password)
+}
+
+// AddPassword adds a password to the keyring used to unseal values. Values
+// name the password they were sealed with by its ID.
+func (i *Iron) AddPassword(id string, password []byte) *Iron {
+	if len(password) < ironMinPassword {
+		i.err = errors.New("securecookie: iron password must be at least 32 bytes")
+	} else if strings.ContainsAny(id, "*~") {
+		i.err = errors.New("securecookie: invalid iron password id")
+	}
+	i.passwords[id] = password
+	return i
+}
+
+// IronSession makes Encode append the "~2" version suffix that
+// iron-session adds to sealed values. Decode accepts values with or
+// without it.
+//
+// Default is false.
+func (i *Iron) IronSession(value bool) *Iron {
+	i.session = value
+	return i
+}
+
+// MaxLength restricts the maximum length, in bytes, for the sealed value.
+//
+// Default is 4096.
+func (i *Iron) MaxLength(value int) *Iron {
+	i.maxLength = value
+	return i
+}
+
+// MaxAge sets the time to live, in seconds, of sealed values. Expired values
+// are always rejected.
+//
+// Default is 86400 * 30. Set it to 0 for values that do not expire.
+func (i *Iron) MaxAge(value int) *Iron {
+	i.maxAge = int64(value)
+	return i
+}
+
+// Encode seals a value.
+//
+// The name argument is ignored.
+func (i *Iron) Encode(name string, value interface{}) (string, error) {
+	if i.err != nil {
+		return "", i.err
+	}
+	b, err := marshalJSON(value)
+	if err != nil {
+		return "", err
+	}
+	encSalt, iv, macSalt := GenerateRandomKey(ironSaltSize), GenerateRandomKey(aes.BlockSize), GenerateRandomKey(ironSaltSize)
+	if encSalt == nil || iv == nil || macSalt == nil {
+		return "", errors.New("securecookie: failed to generate random salt")
+	}
+	var exp string
+	if i.maxAge != 0 {
+		exp = strconv.FormatInt((now(i.timeFunc)+i.maxAge)*1000, 10)
+	}
+	out, err := i.seal(b, hex.EncodeToString(encSalt), iv, hex.EncodeToString(macSalt), exp)
+	if err != nil {
+		return "", err
+	}
+	if i.session {
+		out += "~2"
+	}
+	if i.maxLength != 0 && len(out) > i.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode unseals a value.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (i *Iron) Decode(name, value string, dst interface{}) error {
+	if i.err != nil {
+		return i.err
+	}
+	if i.maxLength != 0 && len(value) > i.maxLength {
+		return ErrTooLong
+	}
+	if j := strings.LastIndexByte(value, '~'); j != -1 {
+		value = value[:j]
+	}
+	parts := strings.Split(value, "*")
+	if len(parts) != 8 || parts[0] != ironPrefix {
+		return ErrMacInvalid
+	}
+	password, ok := i.passwords[parts[1]]
+	if !ok {
+		return ErrIronPassword
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[7])
+	if err != nil {
+		return ErrMacInvalid
+	}
+	macKey, err := ironKey(password, parts[6])
+	if err != nil {
+		return err
+	}
+	base := strings.Join(parts[:6], "*")
+	if err = verifyMac(hmac.New(sha256.New, macKey), []byte(base), mac); err != nil {
+		return err
+	}
+	if parts[5] != "" {
+		exp, err := strconv.ParseInt(parts[5], 10, 64)
+		if err != nil {
+			return ErrTimeInvalid
+		}
+		if exp <= (now(i.timeFunc)-ironMaxSkew)*1000 {
+			return ErrExpired
+		}
+	}
+	iv, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil || len(iv) != aes.BlockSize {
+		return ErrMacInvalid
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return ErrMacInvalid
+	}
+	encKey, err := ironKey(password, parts[2])
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	b, err := pkcs7Unpad(ciphertext, aes.BlockSize)
+	if err != nil {
+		return err
+	}
+	return unmarshalJSON(b, dst)
+}
+
+// seal builds a sealed value from its plaintext, salts, iv and expiry.
+func (i *Iron) seal(plaintext []byte, encSalt string, iv []byte, macSalt, exp string) (string, error) {
+	password := i.passwords[i.id]
+	encKey, err := ironKey(password, encSalt)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := pkcs7Pad(plaintext, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	base := strings.Join([]string{
+		ironPrefix,
+		i.id,
+		encSalt,
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		exp,
+	}, "*")
+	macKey, err := ironKey(password, macSalt)
+	if err != nil {
+		return "", err
+	}
+	mac := createMac(hmac.New(sha256.New, macKey), []byte(base))
+	return base + "*" + macSalt + "*" + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// ironKey derives a 256 bit key from a password and a salt, as iron does.
+func ironKey(password []byte, salt string) ([]byte, error) {
+	return pbkdf2.Key(sha1.New, string(password), []byte(salt), 1, 32)
+}
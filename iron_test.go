@@ -0,0 +1,71 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"strings"
+	"testing"
+)
+
+// Values sealed with Node's crypto module following the iron algorithm.
+const (
+	ironSessionPassword = "complex_password_at_least_32_characters_long"
+	ironSessionValue    = "Fe26.2*1*aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa*AAECAwQFBgcICQoLDA0ODw*r5GS6pdv4Bphjp_BRy66XCMclCX9QKTZtmglcRggkkk*1600001000000*bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb*lVmDnp_HZcVEe7Ju1RDo0fQOOzqd1N77PP7waqegBTc~2"
+	ironHapiPassword    = "some_not_random_password_that_is_also_long_enough"
+	ironHapiValue       = "Fe26.2**cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc*AAECAwQFBgcICQoLDA0ODw*zjeGFwbfUnx3ChMLIyspBA**dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd*yKv8JIpJmpCjp8-S-Yj6m_evsPvHOx0BmY7myGWk9-E"
+)
+
+func TestIronVectors(t *testing.T) {
+	i := NewIron("2", []byte(strings.Repeat("n", 32))).AddPassword("1", []byte(ironSessionPassword))
+	i.timeFunc = func() int64 { return 1600000000 }
+	var session struct {
+		User struct {
+			ID    int  `json:"id"`
+			Admin bool `json:"admin"`
+		} `json:"user"`
+	}
+	if err := i.Decode("", ironSessionValue, &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.User.ID != 7 || !session.User.Admin {
+		t.Errorf("Unexpected value %+v", session)
+	}
+	i.timeFunc = func() int64 { return 1600001000 + ironMaxSkew }
+	if err := i.Decode("", ironSessionValue, &session); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+
+	h := NewIron("", []byte(ironHapiPassword))
+	var dst map[string]int
+	if err := h.Decode("", ironHapiValue, &dst); err != nil || dst["a"] != 1 {
+		t.Errorf("Unexpected value %v (%v)", dst, err)
+	}
+	if err := h.Decode("", ironSessionValue, &dst); err != ErrIronPassword {
+		t.Errorf("Expected %v, got %v.", ErrIronPassword, err)
+	}
+	if err := h.Decode("", strings.Replace(ironHapiValue, "*zje", "*zjf", 1), &dst); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+}
+
+func TestIronRoundtrip(t *testing.T) {
+	i := NewIron("1", []byte(ironSessionPassword)).IronSession(true)
+	encoded, err := i.Encode("", map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "Fe26.2*1*") || !strings.HasSuffix(encoded, "~2") {
+		t.Errorf("Unexpected format %v", encoded)
+	}
+	rotated := NewIron("2", []byte(strings.Repeat("n", 32))).AddPassword("1", []byte(ironSessionPassword))
+	var dst map[string]string
+	if err = rotated.Decode("", encoded, &dst); err != nil || dst["foo"] != "bar" {
+		t.Errorf("Unexpected value %v (%v)", dst, err)
+	}
+	if _, err = NewIron("1", []byte("short")).Encode("", "value"); err == nil {
+		t.Error("Expected failure encoding with a short password.")
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strings"
+)
+
+// Salts used by itsdangerous and Flask.
+const (
+	ItsDangerousDefaultSalt = "itsdangerous"
+	FlaskSessionSalt        = "cookie-session"
+)
+
+// Key derivation modes of itsdangerous.
+const (
+	KeyDerivationDjangoConcat = "django-concat"
+	KeyDerivationConcat       = "concat"
+	KeyDerivationHMAC         = "hmac"
+	KeyDerivationNone         = "none"
+)
+
+// ItsDangerous encodes and decodes values in the format of the itsdangerous
+// URLSafeTimedSerializer, as used by Flask for sessions and tokens.
+//
+// Values are encoded as JSON, unless they implement Coder, and compressed
+// when that makes them shorter. The timestamp is checked against MaxAge and
+// MinAge. The salt acts as a namespace; the cookie name is not part of the
+// value.
+type ItsDangerous struct {
+	keys             [][]byte
+	salt             string
+	keyDerivation    string
+	hashFunc         func() hash.Hash
+	maxLength        int
+	maxInflateLength int
+	maxAge           int64
+	minAge           int64
+	err              error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewItsDangerous returns a new ItsDangerous codec.
+//
+// At least one secret key is required. The first key is used to sign values
+// and all of them are tried to verify values, which allows key rotation.
+// Note that the keys are listed newest first, unlike the secret_key list of
+// itsdangerous.
+func NewItsDangerous(secretKeys ...[]byte) *ItsDangerous {
+	d := &ItsDangerous{
+		keys:             secretKeys,
+		salt:             ItsDangerousDefaultSalt,
+		keyDerivation:    KeyDerivationDjangoConcat,
+		hashFunc:         sha1.New,
+		maxAge:           86400 * 30,
+		maxLength:        4096,
+		maxInflateLength: 65536,
+	}
+	if len(secretKeys) == 0 {
+		d.err = ErrHashKeyNotSet
+	}
+	return d
+}
+
+// Salt sets the salt used as a namespace for signatures. Use
+// FlaskSessionSalt, with KeyDerivationHMAC, for Flask sessions.
+//
+// Default is ItsDangerousDefaultSalt.
+func (d *ItsDangerous) Salt(salt string) *ItsDangerous {
+	d.salt = salt
+	return d
+}
+
+// KeyDerivation sets how the signing key is derived from the secret key and
+// the salt: KeyDerivationDjangoConcat, KeyDerivationConcat,
+// KeyDerivationHMAC or KeyDerivationNone.
+//
+// Default is KeyDerivationDjangoConcat.
+func (d *ItsDangerous) KeyDerivation(mode string) *ItsDangerous {
+	switch mode {
+	case KeyDerivationDjangoConcat, KeyDerivationConcat, KeyDerivationHMAC, KeyDerivationNone:
+		d.keyDerivation = mode
+	default:
+		d.err = errors.New("securecookie: unknown key derivation " + mode)
+	}
+	return d
+}
+
+// HashFunc sets the hash function used for key derivation and the HMAC.
+//
+// Default is crypto/sha1.New.
+func (d *ItsDangerous) HashFunc(f func() hash.Hash) *ItsDangerous {
+	d.hashFunc = f
+	return d
+}
+
+// MaxInflateLength restricts the maximum length, in bytes, of a
+// decompressed value.
+//
+// Default is 65536.
+func (d *ItsDangerous) MaxInflateLength(value int) *ItsDangerous {
+	d.maxInflateLength = value
+	return d
+}
+
+// MaxLength restricts the maximum length, in bytes, for the signed value.
+//
+// Default is 4096.
+func (d *ItsDangerous) MaxLength(value int) *ItsDangerous {
+	d.maxLength = value
+	return d
+}
+
+// MaxAge restricts the maximum age, in seconds, for the signed value. It
+// plays the role of the max_age argument of loads.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (d *ItsDangerous) MaxAge(value int) *ItsDangerous {
+	d.maxAge = int64(value)
+	return d
+}
+
+// MinAge restricts the minimum age, in seconds, for the signed value.
+//
+// Default is 0 (no restriction). Values signed in the future are always
+// rejected.
+func (d *ItsDangerous) MinAge(value int) *ItsDangerous {
+	d.minAge = int64(value)
+	return d
+}
+
+// Encode encodes a value as URLSafeTimedSerializer.dumps does.
+//
+// The name argument is ignored.
+func (d *ItsDangerous) Encode(name string, value interface{}) (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	b, err := marshalJSON(value)
+	if err != nil {
+		return "", err
+	}
+	prefix := ""
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err = w.Write(b); err != nil {
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	if buf.Len() < len(b)-1 {
+		b, prefix = buf.Bytes(), "."
+	}
+	ts := binary.BigEndian.AppendUint64(nil, uint64(now(d.timeFunc)))
+	ts = bytes.TrimLeft(ts, "\x00")
+	out := prefix + base64.RawURLEncoding.EncodeToString(b) + "." + base64.RawURLEncoding.EncodeToString(ts)
+	out += "." + base64.RawURLEncoding.EncodeToString(d.signature(d.keys[0], out))
+	if d.maxLength != 0 && len(out) > d.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a value as URLSafeTimedSerializer.loads does.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (d *ItsDangerous) Decode(name, value string, dst interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.maxLength != 0 && len(value) > d.maxLength {
+		return ErrTooLong
+	}
+	i := strings.LastIndexByte(value, '.')
+	if i == -1 {
+		return ErrMacInvalid
+	}
+	signed := value[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return ErrMacInvalid
+	}
+	valid := false
+	for _, key := range d.keys {
+		if hmac.Equal(sig, d.signature(key, signed)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrMacInvalid
+	}
+	i = strings.LastIndexByte(signed, '.')
+	if i == -1 {
+		return ErrTimeInvalid
+	}
+	ts, err := base64.RawURLEncoding.DecodeString(signed[i+1:])
+	if err != nil || len(ts) > 8 {
+		return ErrTimeInvalid
+	}
+	t1 := int64(binary.BigEndian.Uint64(append(make([]byte, 8-len(ts)), ts...)))
+	t2 := now(d.timeFunc)
+	if t1 > t2 {
+		return ErrTooNew
+	}
+	if err = checkAge(t1, t2, d.minAge, d.maxAge); err != nil {
+		return err
+	}
+	data := signed[:i]
+	compressed := strings.HasPrefix(data, ".")
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(data, "."))
+	if err != nil {
+		return err
+	}
+	if compressed {
+		r, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		if b, err = readAll(r, d.maxInflateLength); err != nil {
+			return err
+		}
+	}
+	return unmarshalJSON(b, dst)
+}
+
+// signature returns the HMAC of a value with the key derived from secret.
+func (d *ItsDangerous) signature(secret []byte, value string) []byte {
+	var key []byte
+	switch d.keyDerivation {
+	case KeyDerivationDjangoConcat:
+		key = createMac(d.hashFunc(), []byte(d.salt+"signer"+string(secret)))
+	case KeyDerivationConcat:
+		key = createMac(d.hashFunc(), []byte(d.salt+string(secret)))
+	case KeyDerivationHMAC:
+		key = createMac(hmac.New(d.hashFunc, secret), []byte(d.salt))
+	default:
+		key = secret
+	}
+	return createMac(hmac.New(d.hashFunc, key), []byte(value))
+}
@@ -0,0 +1,82 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"testing"
+)
+
+// Values in the format of URLSafeTimedSerializer.dumps, signed with the
+// secret key "flask-secret" at timestamp 1600000000.
+const (
+	itsDangerousValue   = "eyJ1c2VyIjoiYWxpY2UifQ.X14QAA.WALyJjkcp8R1i3QPkv84pK8wPb4"
+	itsDangerousSession = ".eJyrVoovSC3KTcxLzStRsiopKk3VUUpOLAKyo5USCwpyUpV0BikdWwsAlXU8aA.X14QAA.Y2uRZEpuFAkLe2DBON7G-fpC5gY"
+)
+
+func TestItsDangerousVectors(t *testing.T) {
+	d := NewItsDangerous([]byte("flask-secret"))
+	d.timeFunc = func() int64 { return 1600000000 }
+	got, err := d.Encode("", map[string]string{"user": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != itsDangerousValue {
+		t.Errorf("Expected %v, got %v.", itsDangerousValue, got)
+	}
+	var dst map[string]string
+	if err = d.Decode("", itsDangerousValue, &dst); err != nil || dst["user"] != "alice" {
+		t.Errorf("Unexpected value %v (%v)", dst, err)
+	}
+
+	s := NewItsDangerous([]byte("flask-secret")).Salt(FlaskSessionSalt).KeyDerivation(KeyDerivationHMAC)
+	s.timeFunc = d.timeFunc
+	var session struct {
+		Permanent bool     `json:"_permanent"`
+		Cart      []string `json:"cart"`
+	}
+	if err = s.Decode("", itsDangerousSession, &session); err != nil {
+		t.Fatal(err)
+	}
+	if !session.Permanent || len(session.Cart) != 20 {
+		t.Errorf("Unexpected value %+v", session)
+	}
+	if err = d.Decode("", itsDangerousSession, &session); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+}
+
+func TestItsDangerousRotation(t *testing.T) {
+	var ts int64 = 1600000000
+	oldCodec := NewItsDangerous([]byte("old")).MaxAge(60)
+	oldCodec.timeFunc = func() int64 { return ts }
+	encoded, err := oldCodec.Encode("", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := NewItsDangerous([]byte("new"), []byte("old")).MaxAge(60)
+	rotated.timeFunc = oldCodec.timeFunc
+	tests := []struct {
+		Codec *ItsDangerous
+		Now   int64
+		Err   error
+	}{
+		{rotated, ts, nil},
+		{NewItsDangerous([]byte("new")), ts, ErrMacInvalid},
+		{rotated, ts - 1, ErrTooNew},
+		{rotated, ts + 61, ErrExpired},
+	}
+	for i, test := range tests {
+		ts = test.Now
+		test.Codec.timeFunc = func() int64 { return ts }
+		var dst string
+		if err = test.Codec.Decode("", encoded, &dst); err != test.Err {
+			t.Errorf("%d: expected %v, got %v", i, test.Err, err)
+		}
+	}
+	if NewItsDangerous([]byte("k")).KeyDerivation("bogus").err == nil {
+		t.Error("Expected an error for an unknown key derivation.")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+var (
+	ErrJSONDepth        = errors.New("securecookie: json value exceeds the maximum depth")
+	ErrJSONTrailingData = errors.New("securecookie: trailing data after json value")
+)
+
+// Number modes of JSONSerializer, which set how JSON numbers are decoded
+// into interface{} values.
+const (
+	// JSONNumberFloat64 decodes numbers as float64, as encoding/json does.
+	JSONNumberFloat64 = iota
+	// JSONNumberJSON decodes numbers as json.Number.
+	JSONNumberJSON
+	// JSONNumberInt64 decodes integers as int64 and other numbers as
+	// float64.
+	JSONNumberInt64
+)
+
+// JSONSerializer encodes cookie values using encoding/json.
+//
+// It is safe for concurrent use and needs no registration of types.
+type JSONSerializer struct {
+	strict     bool
+	numberMode int
+	maxDepth   int
+}
+
+// NewJSONSerializer returns a new JSONSerializer.
+func NewJSONSerializer() *JSONSerializer {
+	return &JSONSerializer{
+		numberMode: JSONNumberFloat64,
+		maxDepth:   32,
+	}
+}
+
+// Strict makes Deserialize reject objects with fields that do not exist in
+// the destination struct, and data following the JSON value.
+//
+// Default is false.
+func (j *JSONSerializer) Strict(value bool) *JSONSerializer {
+	j.strict = value
+	return j
+}
+
+// NumberMode sets how numbers are decoded into interface{} values, such as
+// the values of a map[string]interface{}: JSONNumberFloat64, JSONNumberJSON
+// or JSONNumberInt64.
+//
+// Default is JSONNumberFloat64.
+func (j *JSONSerializer) NumberMode(mode int) *JSONSerializer {
+	j.numberMode = mode
+	return j
+}
+
+// MaxDepth restricts the nesting depth of arrays and objects accepted by
+// Deserialize.
+//
+// Default is 32. Set it to 0 for no restriction.
+func (j *JSONSerializer) MaxDepth(value int) *JSONSerializer {
+	j.maxDepth = value
+	return j
+}
+
+// Serialize encodes a value using encoding/json.
+func (j *JSONSerializer) Serialize(src interface{}) ([]byte, error) {
+	return json.Marshal(src)
+}
+
+// Deserialize decodes a value using encoding/json.
+func (j *JSONSerializer) Deserialize(src []byte, dst interface{}) error {
+	if j.maxDepth != 0 && jsonDepth(src) > j.maxDepth {
+		return ErrJSONDepth
+	}
+	dec := json.NewDecoder(bytes.NewReader(src))
+	if j.strict {
+		dec.DisallowUnknownFields()
+	}
+	if j.numberMode != JSONNumberFloat64 {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	if j.strict {
+		if _, err := dec.Token(); err != io.EOF {
+			return ErrJSONTrailingData
+		}
+	}
+	if j.numberMode == JSONNumberInt64 {
+		convertNumbers(reflect.ValueOf(dst))
+	}
+	return nil
+}
+
+// jsonDepth returns the maximum nesting depth of arrays and objects in a
+// JSON document, without fully parsing it.
+func jsonDepth(b []byte) int {
+	depth, max := 0, 0
+	inString, escaped := false, false
+	for _, c := range b {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[' || c == '{':
+			if depth++; depth > max {
+				max = depth
+			}
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return max
+}
+
+// convertNumbers replaces the json.Number values held by interface{} values
+// reachable from v with an int64, or a float64 if they are not integers.
+func convertNumbers(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			convertNumbers(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		if n, ok := e.Interface().(json.Number); ok {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(numberValue(n)))
+			}
+			return
+		}
+		if e.Kind() == reflect.Map || e.Kind() == reflect.Slice {
+			// Maps and slices share their storage, so they can be updated
+			// through a copy.
+			convertNumbers(e)
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.Interface {
+			for _, k := range v.MapKeys() {
+				convertNumbers(v.MapIndex(k))
+			}
+			return
+		}
+		for _, k := range v.MapKeys() {
+			e := v.MapIndex(k)
+			if n, ok := e.Interface().(json.Number); ok {
+				v.SetMapIndex(k, reflect.ValueOf(numberValue(n)))
+			} else if !e.IsNil() {
+				convertNumbers(e.Elem())
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			convertNumbers(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				convertNumbers(v.Field(i))
+			}
+		}
+	}
+}
+
+// numberValue returns n as an int64 if it is an integer, or as a float64.
+func numberValue(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
@@ -0,0 +1,94 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	s := New([]byte("12345"), []byte("1234567890123456")).
+		SetSerializer(NewJSONSerializer().NumberMode(JSONNumberInt64))
+	value := map[string]interface{}{
+		"foo":  "bar",
+		"baz":  128,
+		"list": []interface{}{1, 2.5},
+	}
+	encoded, err := s.Encode("sid", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make(map[string]interface{})
+	if err = s.Decode("sid", encoded, &dst); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"foo":  "bar",
+		"baz":  int64(128),
+		"list": []interface{}{int64(1), 2.5},
+	}
+	if !reflect.DeepEqual(dst, expected) {
+		t.Errorf("Expected %v, got %v.", expected, dst)
+	}
+}
+
+func TestJSONSerializerNumberMode(t *testing.T) {
+	src := []byte(`{"n":12345678901234567890,"m":{"i":7}}`)
+	for mode, expected := range map[int]interface{}{
+		JSONNumberFloat64: 7.0,
+		JSONNumberJSON:    json.Number("7"),
+		JSONNumberInt64:   int64(7),
+	} {
+		var dst map[string]interface{}
+		if err := NewJSONSerializer().NumberMode(mode).Deserialize(src, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if got := dst["m"].(map[string]interface{})["i"]; got != expected {
+			t.Errorf("Expected %#v, got %#v.", expected, got)
+		}
+	}
+	var dst map[string]interface{}
+	NewJSONSerializer().NumberMode(JSONNumberInt64).Deserialize(src, &dst)
+	if _, ok := dst["n"].(float64); !ok {
+		t.Errorf("Expected a float64 for an integer overflowing int64, got %#v.", dst["n"])
+	}
+}
+
+func TestJSONSerializerStrict(t *testing.T) {
+	var dst struct {
+		Foo int
+	}
+	lax, strict := NewJSONSerializer(), NewJSONSerializer().Strict(true)
+	for _, src := range []string{`{"Foo":1,"Bar":2}`, `{"Foo":1} {"Foo":2}`} {
+		if err := lax.Deserialize([]byte(src), &dst); err != nil || dst.Foo != 1 {
+			t.Errorf("Unexpected value %+v (%v)", dst, err)
+		}
+		if err := strict.Deserialize([]byte(src), &dst); err == nil {
+			t.Errorf("Expected failure decoding %s.", src)
+		}
+	}
+	if err := strict.Deserialize([]byte(`{"Foo":1} {}`), &dst); err != ErrJSONTrailingData {
+		t.Errorf("Expected %v, got %v.", ErrJSONTrailingData, err)
+	}
+}
+
+func TestJSONSerializerDepth(t *testing.T) {
+	var dst interface{}
+	deep := strings.Repeat("[", 33) + strings.Repeat("]", 33)
+	if err := NewJSONSerializer().Deserialize([]byte(deep), &dst); err != ErrJSONDepth {
+		t.Errorf("Expected %v, got %v.", ErrJSONDepth, err)
+	}
+	if err := NewJSONSerializer().MaxDepth(0).Deserialize([]byte(deep), &dst); err != nil {
+		t.Error(err)
+	}
+	// Brackets in strings do not count.
+	if err := NewJSONSerializer().Deserialize([]byte(`["`+deep+`\"["]`), &dst); err != nil {
+		t.Error(err)
+	}
+}
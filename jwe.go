@@ -0,0 +1,257 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrJWEHeader = errors.New("securecookie: invalid jwe header")
+	ErrJWEKeyID  = errors.New("securecookie: jwe key id does not match")
+)
+
+// jweHeader is the JOSE header of an encrypted token.
+type jweHeader struct {
+	// Fields are in the order used by the examples of RFC 7520.
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid,omitempty"`
+	Enc  string   `json:"enc"`
+	Zip  string   `json:"zip,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+// JWE encodes and decodes values as JWE compact serialized tokens using
+// direct encryption ("dir") with AES-GCM.
+//
+// Values that are a []byte, a string or implement Coder are encrypted as
+// is. Other values are encoded as JSON and their "exp", "nbf" and "iat"
+// claims are handled as for JWS. The claims of a token are validated whatever
+// the Decode destination. The cookie name is not part of the token.
+type JWE struct {
+	aead             cipher.AEAD
+	enc              string
+	kid              string
+	compress         bool
+	maxLength        int
+	maxInflateLength int
+	maxAge           int64
+	minAge           int64
+	err              error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewJWE returns a new JWE codec.
+//
+// blockKey is the content encryption key, as used by New. Its length
+// selects the "enc" algorithm: 16, 24 or 32 bytes select A128GCM, A192GCM
+// or A256GCM.
+func NewJWE(blockKey []byte) *JWE {
+	j := &JWE{
+		maxAge:           86400 * 30,
+		maxLength:        4096,
+		maxInflateLength: 65536,
+	}
+	switch len(blockKey) {
+	case 16:
+		j.enc = "A128GCM"
+	case 24:
+		j.enc = "A192GCM"
+	case 32:
+		j.enc = "A256GCM"
+	default:
+		j.err = errors.New("securecookie: jwe key must be 16, 24 or 32 bytes")
+		return j
+	}
+	block, err := aes.NewCipher(blockKey)
+	if err != nil {
+		j.err = err
+		return j
+	}
+	j.aead, j.err = cipher.NewGCM(block)
+	return j
+}
+
+// KeyID sets the "kid" header of encoded tokens. Tokens that carry a
+// different key ID are rejected without decrypting them.
+//
+// Default is no key ID.
+func (j *JWE) KeyID(kid string) *JWE {
+	j.kid = kid
+	return j
+}
+
+// Compress enables DEFLATE compression ("zip": "DEF") of encoded tokens.
+// Compressed tokens are always accepted by Decode.
+//
+// Default is false.
+func (j *JWE) Compress(value bool) *JWE {
+	j.compress = value
+	return j
+}
+
+// MaxInflateLength restricts the maximum length, in bytes, of a
+// decompressed value.
+//
+// Default is 65536.
+func (j *JWE) MaxInflateLength(value int) *JWE {
+	j.maxInflateLength = value
+	return j
+}
+
+// MaxLength restricts the maximum length, in bytes, for the token.
+//
+// Default is 4096.
+func (j *JWE) MaxLength(value int) *JWE {
+	j.maxLength = value
+	return j
+}
+
+// MaxAge restricts the maximum age, in seconds, for the token.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (j *JWE) MaxAge(value int) *JWE {
+	j.maxAge = int64(value)
+	return j
+}
+
+// MinAge restricts the minimum age, in seconds, for the token.
+//
+// Default is 0 (no restriction).
+func (j *JWE) MinAge(value int) *JWE {
+	j.minAge = int64(value)
+	return j
+}
+
+// Encode encodes a value as an encrypted JWE token.
+//
+// The name argument is ignored.
+func (j *JWE) Encode(name string, value interface{}) (string, error) {
+	if j.err != nil {
+		return "", j.err
+	}
+	plaintext, err := marshalBytes(value)
+	if err == ErrUnsupportedValue {
+		plaintext, err = jwtClaims.marshal(value, now(j.timeFunc), j.minAge, j.maxAge)
+	}
+	if err != nil {
+		return "", err
+	}
+	header := jweHeader{Alg: "dir", Enc: j.enc, Kid: j.kid}
+	if j.compress {
+		header.Zip = "DEF"
+		if plaintext, err = deflate(plaintext); err != nil {
+			return "", err
+		}
+	}
+	iv := GenerateRandomKey(j.aead.NonceSize())
+	if iv == nil {
+		return "", errors.New("securecookie: failed to generate random iv")
+	}
+	out, err := j.seal(header, iv, plaintext)
+	if err != nil {
+		return "", err
+	}
+	if j.maxLength != 0 && len(out) > j.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes an encrypted JWE token.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (j *JWE) Decode(name, value string, dst interface{}) error {
+	if j.err != nil {
+		return j.err
+	}
+	if j.maxLength != 0 && len(value) > j.maxLength {
+		return ErrTooLong
+	}
+	plaintext, err := j.open(value)
+	if err != nil {
+		return err
+	}
+	// Claims are checked whatever the destination, but raw values are not
+	// given claims by Encode.
+	raw := false
+	switch dst.(type) {
+	case Coder, *[]byte, *string:
+		raw = true
+	}
+	if err = jwtClaims.check(plaintext, now(j.timeFunc), j.minAge, j.maxAge, !raw); err != nil {
+		return err
+	}
+	if raw {
+		return unmarshalBytes(plaintext, dst)
+	}
+	return json.Unmarshal(plaintext, dst)
+}
+
+// seal encrypts a plaintext with the given header and iv.
+func (j *JWE) seal(header jweHeader, iv, plaintext []byte) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	aad := base64.RawURLEncoding.EncodeToString(h)
+	sealed := j.aead.Seal(nil, iv, plaintext, []byte(aad))
+	tagStart := len(sealed) - j.aead.Overhead()
+	return strings.Join([]string{
+		aad,
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(sealed[:tagStart]),
+		base64.RawURLEncoding.EncodeToString(sealed[tagStart:]),
+	}, "."), nil
+}
+
+// open checks the header of a token, decrypts it and decompresses it.
+func (j *JWE) open(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return nil, ErrJWEHeader
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrJWEHeader
+	}
+	var header jweHeader
+	if err = json.Unmarshal(b, &header); err != nil {
+		return nil, ErrJWEHeader
+	}
+	if header.Alg != "dir" || header.Enc != j.enc || len(header.Crit) != 0 ||
+		(header.Zip != "" && header.Zip != "DEF") {
+		return nil, ErrJWEHeader
+	}
+	if j.kid != "" && header.Kid != "" && header.Kid != j.kid {
+		return nil, ErrJWEKeyID
+	}
+	var iv, ciphertext, tag []byte
+	for i, dst := range []*[]byte{&iv, &ciphertext, &tag} {
+		if *dst, err = base64.RawURLEncoding.DecodeString(parts[i+2]); err != nil {
+			return nil, ErrMacInvalid
+		}
+	}
+	if len(iv) != j.aead.NonceSize() || len(tag) != j.aead.Overhead() {
+		return nil, ErrMacInvalid
+	}
+	plaintext, err := j.aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, ErrMacInvalid
+	}
+	if header.Zip == "DEF" {
+		return inflate(plaintext, j.maxInflateLength)
+	}
+	return plaintext, nil
+}
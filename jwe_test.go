@@ -0,0 +1,113 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// Example from RFC 7520, section 5.6.
+const (
+	jweExampleKey   = "XctOhJAkA-pD9Lh7ZgW_2A"
+	jweExampleKid   = "77c7e2b8-6e13-45cf-8672-617b5b45243a"
+	jweExampleIV    = "refa467QzzKx6QAB"
+	jweExampleToken = "eyJhbGciOiJkaXIiLCJraWQiOiI3N2M3ZTJiOC02ZTEzLTQ1Y2YtODY3Mi02MTdiNWI0NTI0M2EiLCJlbmMiOiJBMTI4R0NNIn0" +
+		"..refa467QzzKx6QAB." +
+		"JW_i_f52hww_ELQPGaYyeAB6HYGcR559l9TYnSovc23XJoBcW29rHP8yZOZG7YhLpT1bjFuvZPjQS-m0IFtVcXkZXdH_lr_FrdYt9HRUYkshtrMmIUAyGmUnd9zMDB2n0cRDIHAzFVeJUDxkUwVAE7_YGRPdcqMyiBoCO-FBdE-Nceb4h3-FtBP-c_BIwCPTjb9o0SbdcdREEMJMyZBH8ySWMVi1gPD9yxi-aQpGbSv_F9N4IZAxscj5g-NJsUPbjk29-s7LJAGb15wEBtXphVCgyy53CoIKLHHeJHXex45Uz9aKZSRSInZI-wjsY0yu3cT4_aQ3i1o-tiE-F8Ios61EKgyIQ4CWao8PFMj8TTnp" +
+		".vbb32Xvllea2OtmHAdccRQ"
+	jweExamplePlaintext = "You can trust us to stick with you through thick and thin–to the bitter end. And you can trust us to keep any secret of yours–closer than you keep it yourself. But you cannot trust us to let you face trouble alone, and go off without a word. We are your friends, Frodo."
+)
+
+func TestJWEExample(t *testing.T) {
+	key, _ := base64.RawURLEncoding.DecodeString(jweExampleKey)
+	j := NewJWE(key).KeyID(jweExampleKid)
+	var dst string
+	if err := j.Decode("", jweExampleToken, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst != jweExamplePlaintext {
+		t.Errorf("Expected %q, got %q.", jweExamplePlaintext, dst)
+	}
+	// Sealing with the IV of the example yields the same token.
+	iv, _ := base64.RawURLEncoding.DecodeString(jweExampleIV)
+	header := jweHeader{Alg: "dir", Kid: jweExampleKid, Enc: "A128GCM"}
+	token, err := j.seal(header, iv, []byte(jweExamplePlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != jweExampleToken {
+		t.Errorf("Expected %v, got %v.", jweExampleToken, token)
+	}
+	if err := NewJWE(key).KeyID("other").Decode("", jweExampleToken, &dst); err != ErrJWEKeyID {
+		t.Errorf("Expected %v, got %v.", ErrJWEKeyID, err)
+	}
+	if err := NewJWE(GenerateRandomKey(32)).Decode("", jweExampleToken, &dst); err != ErrJWEHeader {
+		t.Errorf("Expected %v, got %v.", ErrJWEHeader, err)
+	}
+}
+
+func TestJWECompression(t *testing.T) {
+	j := NewJWE(GenerateRandomKey(32)).Compress(true)
+	value := strings.Repeat("thick and thin ", 100)
+	encoded, err := j.Encode("", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoded) > 300 {
+		t.Errorf("Expected a compressed token, got %d bytes.", len(encoded))
+	}
+	var dst string
+	if err = j.Decode("", encoded, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst != value {
+		t.Errorf("Expected %q, got %q.", value, dst)
+	}
+	if err = j.MaxInflateLength(1000).Decode("", encoded, &dst); err != ErrTooLong {
+		t.Errorf("Expected %v, got %v.", ErrTooLong, err)
+	}
+}
+
+func TestJWEClaims(t *testing.T) {
+	var ts int64 = 1600000000
+	j := NewJWE(GenerateRandomKey(16)).MaxAge(60)
+	j.timeFunc = func() int64 { return ts }
+	encoded, err := j.Encode("", &FooBar{42, "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &FooBar{}
+	if err = j.Decode("", encoded, dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Foo != 42 || dst.Bar != "bar" {
+		t.Errorf("Expected %#v, got %#v", FooBar{42, "bar"}, dst)
+	}
+	ts += 61
+	if err = j.Decode("", encoded, dst); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+	// Raw destinations do not skip the claims.
+	var raw []byte
+	if err = j.Decode("", encoded, &raw); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+	var str string
+	if err = j.Decode("", encoded, &str); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+	i := strings.LastIndexByte(encoded, '.') + 1
+	c := byte('A')
+	if encoded[i] == c {
+		c = 'B'
+	}
+	tampered := encoded[:i] + string(c) + encoded[i+1:]
+	if err = j.Decode("", tampered, dst); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"strings"
+)
+
+var (
+	ErrJWSAlgorithm = errors.New("securecookie: unexpected jws algorithm")
+	ErrJWSHeader    = errors.New("securecookie: invalid jws header")
+	ErrJWSKeyID     = errors.New("securecookie: jws key id does not match")
+	ErrJWTClaims    = errors.New("securecookie: invalid jwt claims")
+)
+
+// jwsAlgorithms maps the supported JWS algorithms to their hash functions.
+var jwsAlgorithms = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// jwsHeader is the JOSE header of a token.
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid,omitempty"`
+	Typ  string   `json:"typ,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+// JWS encodes and decodes values as JWS compact serialized tokens (JWTs)
+// signed with HMAC.
+//
+// Values are encoded as JSON, unless they implement Coder. When the value
+// encodes to a JSON object, the "iat" claim is added, as are the "nbf" and
+// "exp" claims when MinAge and MaxAge are set. On decoding, the "exp", "nbf"
+// and "iat" claims are validated and MinAge and MaxAge are applied to "iat".
+// Other values, like strings, and values that implement Coder carry no
+// claims, so MinAge and MaxAge do not apply to them.
+//
+// Tokens must use exactly the algorithm the codec was created with, so
+// "none" and tokens meant for other algorithms are rejected. For key
+// rotation, give each codec a key ID and pass them to EncodeMulti and
+// DecodeMulti. The cookie name is not part of the token.
+type JWS struct {
+	alg       string
+	hashFunc  func() hash.Hash
+	key       []byte
+	kid       string
+	maxLength int
+	maxAge    int64
+	minAge    int64
+	err       error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewJWS returns a new JWS codec.
+//
+// alg is one of "HS256", "HS384" or "HS512". key is the HMAC key; it must
+// be at least as long as the hash output, as required by RFC 7518.
+func NewJWS(alg string, key []byte) *JWS {
+	j := &JWS{
+		alg:       alg,
+		key:       key,
+		maxAge:    86400 * 30,
+		maxLength: 4096,
+	}
+	j.hashFunc = jwsAlgorithms[alg]
+	if j.hashFunc == nil {
+		j.err = ErrJWSAlgorithm
+	} else if len(key) < j.hashFunc().Size() {
+		j.err = errors.New("securecookie: jws key is too short")
+	}
+	return j
+}
+
+// KeyID sets the "kid" header of encoded tokens. Tokens that carry a
+// different key ID are rejected without verifying their signature.
+//
+// Default is no key ID.
+func (j *JWS) KeyID(kid string) *JWS {
+	j.kid = kid
+	return j
+}
+
+// MaxLength restricts the maximum length, in bytes, for the token.
+//
+// Default is 4096.
+func (j *JWS) MaxLength(value int) *JWS {
+	j.maxLength = value
+	return j
+}
+
+// MaxAge restricts the maximum age, in seconds, for the token.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (j *JWS) MaxAge(value int) *JWS {
+	j.maxAge = int64(value)
+	return j
+}
+
+// MinAge restricts the minimum age, in seconds, for the token.
+//
+// Default is 0 (no restriction).
+func (j *JWS) MinAge(value int) *JWS {
+	j.minAge = int64(value)
+	return j
+}
+
+// Encode encodes a value as a signed JWS token.
+//
+// The name argument is ignored.
+func (j *JWS) Encode(name string, value interface{}) (string, error) {
+	if j.err != nil {
+		return "", j.err
+	}
+	header, err := json.Marshal(jwsHeader{Alg: j.alg, Kid: j.kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := jwtClaims.marshal(value, now(j.timeFunc), j.minAge, j.maxAge)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := createMac(hmac.New(j.hashFunc, j.key), []byte(input))
+	out := input + "." + base64.RawURLEncoding.EncodeToString(mac)
+	if j.maxLength != 0 && len(out) > j.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a signed JWS token.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (j *JWS) Decode(name, value string, dst interface{}) error {
+	if j.err != nil {
+		return j.err
+	}
+	if j.maxLength != 0 && len(value) > j.maxLength {
+		return ErrTooLong
+	}
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return ErrJWSHeader
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrJWSHeader
+	}
+	var header jwsHeader
+	if err = json.Unmarshal(b, &header); err != nil {
+		return ErrJWSHeader
+	}
+	if header.Alg != j.alg {
+		return ErrJWSAlgorithm
+	}
+	if len(header.Crit) != 0 {
+		return ErrJWSHeader
+	}
+	if j.kid != "" && header.Kid != "" && header.Kid != j.kid {
+		return ErrJWSKeyID
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMacInvalid
+	}
+	input := value[:len(parts[0])+len(parts[1])+1]
+	if err = verifyMac(hmac.New(j.hashFunc, j.key), []byte(input), mac); err != nil {
+		return err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	return jwtClaims.unmarshal(payload, dst, now(j.timeFunc), j.minAge, j.maxAge)
+}
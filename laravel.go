@@ -0,0 +1,232 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var ErrLaravelCipher = errors.New("securecookie: unsupported laravel cipher")
+
+// laravelPayload is the JSON envelope of an encrypted value.
+type laravelPayload struct {
+	IV    string `json:"iv"`
+	Value string `json:"value"`
+	MAC   string `json:"mac"`
+	Tag   string `json:"tag"`
+}
+
+// Laravel encodes and decodes values in the format of the Laravel
+// encrypter, as used by the EncryptCookies middleware.
+//
+// Values that are a []byte, a string or implement Coder are encrypted as
+// is; other values are encoded as JSON. PHP serialization is not supported,
+// which matches the default for cookies. When a cookie name is given, the
+// value is prefixed with the cookie name hash Laravel uses to bind values
+// to their cookie. Laravel values carry no timestamp, so expiry is left to
+// the cookie attributes.
+type Laravel struct {
+	key       []byte
+	cipher    string
+	block     cipher.Block
+	aead      cipher.AEAD
+	maxLength int
+	err       error
+}
+
+// NewLaravel returns a new Laravel codec.
+//
+// key is the decoded APP_KEY; use ParseLaravelKey to decode it. A 16 byte
+// key selects AES-128-CBC and a 32 byte key AES-256-CBC; use Cipher to
+// select AES-GCM instead.
+func NewLaravel(key []byte) *Laravel {
+	l := &Laravel{
+		key:       key,
+		maxLength: 4096,
+	}
+	switch len(key) {
+	case 16:
+		return l.Cipher("AES-128-CBC")
+	case 32:
+		return l.Cipher("AES-256-CBC")
+	}
+	l.err = ErrLaravelCipher
+	return l
+}
+
+// ParseLaravelKey decodes an APP_KEY, with or without its "base64:" prefix.
+func ParseLaravelKey(appKey string) ([]byte, error) {
+	if !strings.HasPrefix(appKey, "base64:") {
+		return []byte(appKey), nil
+	}
+	return base64.StdEncoding.DecodeString(appKey[len("base64:"):])
+}
+
+// Cipher sets the cipher, as named in the cipher setting of Laravel:
+// "AES-128-CBC", "AES-256-CBC", "AES-128-GCM" or "AES-256-GCM". The key
+// length must match the cipher.
+//
+// Default is AES-128-CBC or AES-256-CBC, depending on the key length.
+func (l *Laravel) Cipher(name string) *Laravel {
+	size := map[string]int{
+		"AES-128-CBC": 16, "AES-256-CBC": 32,
+		"AES-128-GCM": 16, "AES-256-GCM": 32,
+	}[strings.ToUpper(name)]
+	if size == 0 || size != len(l.key) {
+		l.err = ErrLaravelCipher
+		return l
+	}
+	l.cipher = strings.ToUpper(name)
+	block, err := aes.NewCipher(l.key)
+	if err != nil {
+		l.err = err
+		return l
+	}
+	l.block, l.aead, l.err = block, nil, nil
+	if strings.HasSuffix(l.cipher, "GCM") {
+		l.aead, l.err = cipher.NewGCM(block)
+	}
+	return l
+}
+
+// MaxLength restricts the maximum length, in bytes, for the cookie value.
+//
+// Default is 4096.
+func (l *Laravel) MaxLength(value int) *Laravel {
+	l.maxLength = value
+	return l
+}
+
+// Encode encrypts a value as Encrypter::encrypt does.
+func (l *Laravel) Encode(name string, value interface{}) (string, error) {
+	if l.err != nil {
+		return "", l.err
+	}
+	plaintext, err := marshalBytes(value)
+	if err == ErrUnsupportedValue {
+		plaintext, err = json.Marshal(value)
+	}
+	if err != nil {
+		return "", err
+	}
+	if name != "" {
+		plaintext = append([]byte(l.cookiePrefix(name)), plaintext...)
+	}
+	var p laravelPayload
+	if l.aead != nil {
+		iv := GenerateRandomKey(l.aead.NonceSize())
+		if iv == nil {
+			return "", errors.New("securecookie: failed to generate random iv")
+		}
+		sealed := l.aead.Seal(nil, iv, plaintext, nil)
+		tagStart := len(sealed) - l.aead.Overhead()
+		p.IV = base64.StdEncoding.EncodeToString(iv)
+		p.Value = base64.StdEncoding.EncodeToString(sealed[:tagStart])
+		p.Tag = base64.StdEncoding.EncodeToString(sealed[tagStart:])
+	} else {
+		iv := GenerateRandomKey(aes.BlockSize)
+		if iv == nil {
+			return "", errors.New("securecookie: failed to generate random iv")
+		}
+		ciphertext := pkcs7Pad(plaintext, aes.BlockSize)
+		cipher.NewCBCEncrypter(l.block, iv).CryptBlocks(ciphertext, ciphertext)
+		p.IV = base64.StdEncoding.EncodeToString(iv)
+		p.Value = base64.StdEncoding.EncodeToString(ciphertext)
+		p.MAC = hex.EncodeToString(createMac(hmac.New(sha256.New, l.key), []byte(p.IV+p.Value)))
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	out := url.QueryEscape(base64.StdEncoding.EncodeToString(b))
+	if l.maxLength != 0 && len(out) > l.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decrypts a value as Encrypter::decrypt does. URL escaped values
+// are accepted.
+func (l *Laravel) Decode(name, value string, dst interface{}) error {
+	if l.err != nil {
+		return l.err
+	}
+	if l.maxLength != 0 && len(value) > l.maxLength {
+		return ErrTooLong
+	}
+	value, err := url.QueryUnescape(value)
+	if err != nil {
+		return err
+	}
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	var p laravelPayload
+	if err = json.Unmarshal(b, &p); err != nil {
+		return ErrMacInvalid
+	}
+	iv, err := base64.StdEncoding.DecodeString(p.IV)
+	if err != nil {
+		return ErrMacInvalid
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(p.Value)
+	if err != nil {
+		return ErrMacInvalid
+	}
+	var plaintext []byte
+	if l.aead != nil {
+		tag, err := base64.StdEncoding.DecodeString(p.Tag)
+		if err != nil || len(iv) != l.aead.NonceSize() || len(tag) != l.aead.Overhead() {
+			return ErrMacInvalid
+		}
+		if plaintext, err = l.aead.Open(nil, iv, append(ciphertext, tag...), nil); err != nil {
+			return ErrMacInvalid
+		}
+	} else {
+		mac, err := hex.DecodeString(p.MAC)
+		if err != nil {
+			return ErrMacInvalid
+		}
+		if err = verifyMac(hmac.New(sha256.New, l.key), []byte(p.IV+p.Value), mac); err != nil {
+			return err
+		}
+		if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+			return ErrMacInvalid
+		}
+		cipher.NewCBCDecrypter(l.block, iv).CryptBlocks(ciphertext, ciphertext)
+		if plaintext, err = pkcs7Unpad(ciphertext, aes.BlockSize); err != nil {
+			return err
+		}
+	}
+	if name != "" {
+		prefix := l.cookiePrefix(name)
+		if len(plaintext) < len(prefix) || !hmac.Equal(plaintext[:len(prefix)], []byte(prefix)) {
+			return ErrMacInvalid
+		}
+		plaintext = plaintext[len(prefix):]
+	}
+	if err = unmarshalBytes(plaintext, dst); err != ErrUnsupportedValue {
+		return err
+	}
+	return json.Unmarshal(plaintext, dst)
+}
+
+// cookiePrefix returns the prefix that binds a value to a cookie name, as
+// computed by CookieValuePrefix::create.
+func (l *Laravel) cookiePrefix(name string) string {
+	return hex.EncodeToString(createMac(hmac.New(sha1.New, l.key), []byte(name+"v2"))) + "|"
+}
@@ -0,0 +1,73 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"strings"
+	"testing"
+)
+
+// Values encrypted with Node's crypto module following Laravel's encrypter
+// and EncryptCookies middleware.
+const (
+	laravelAppKey    = "base64:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	laravelCBCCookie = "eyJpdiI6IkR3NE5EQXNLQ1FnSEJnVUVBd0lCQUE9PSIsInZhbHVlIjoiVEhRYXdLblp0QXFHcUt6WFdOaXlHdXJLWldPOTFRZHc3YXFWMFc2TUNQRDUwMHYrUnlEdGZtaEY3b0RaTDNYMGVIbWNzcFJtMUpBbmh5QWJzWXBpQ3FNcEZBUlZrQTRQd2lPMjNFUG00aTQ3bWtwb3UxZ0xtNlhHSVkzUnhwSlciLCJtYWMiOiI3Y2JjNzc5ZjQwMTc4NTViZTNjMzFiMTI4MjgzZTE4ZjZhZmQ3Yjg1ZGY2ZWRhZjI3YTczYTJiOTY1ODQ3ZDMwIiwidGFnIjoiIn0%3D"
+	laravelGCMCookie = "eyJpdiI6IkR3NE5EQXNLQ1FnSEJnVUUiLCJ2YWx1ZSI6IndRV0NPV3F6bjVmYzZOSTdEcXU4TjFoV2VnVDZQY0JjTTNHZnh3STlnUnN5dlBwM1QzdFB1bHpPRHBuN2FVVmdOVHJYTlFuUE5jbUoiLCJtYWMiOiIiLCJ0YWciOiJhU1VmSFhkUHhid0Y2OFpzRUtieS9nPT0ifQ%3D%3D"
+)
+
+func TestLaravelVectors(t *testing.T) {
+	key, err := ParseLaravelKey(laravelAppKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLaravel(key)
+	var session string
+	if err = l.Decode("laravel_session", laravelCBCCookie, &session); err != nil {
+		t.Fatal(err)
+	}
+	if session != "Hx2bZ5oKq1Zt0uXy9wSg7fVd3Rn8MaJcLpQeTi4B" {
+		t.Errorf("Unexpected value %v", session)
+	}
+	if err = l.Decode("other_cookie", laravelCBCCookie, &session); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+
+	g := NewLaravel(key).Cipher("AES-256-GCM")
+	var prefs struct {
+		Theme string `json:"theme"`
+	}
+	if err = g.Decode("preferences", laravelGCMCookie, &prefs); err != nil || prefs.Theme != "dark" {
+		t.Errorf("Unexpected value %+v (%v)", prefs, err)
+	}
+	if err = l.Decode("preferences", laravelGCMCookie, &prefs); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+}
+
+func TestLaravelRoundtrip(t *testing.T) {
+	key, _ := ParseLaravelKey(laravelAppKey)
+	for _, name := range []string{"AES-256-CBC", "AES-256-GCM"} {
+		l := NewLaravel(key).Cipher(name)
+		encoded, err := l.Encode("cart", map[string]int{"items": 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst map[string]int
+		if err = l.Decode("cart", encoded, &dst); err != nil || dst["items"] != 3 {
+			t.Errorf("%s: unexpected value %v (%v)", name, dst, err)
+		}
+		var raw string
+		if err = l.Decode("", encoded, &raw); err != nil || !strings.HasSuffix(raw, `|{"items":3}`) {
+			t.Errorf("%s: unexpected value %v (%v)", name, raw, err)
+		}
+	}
+	if _, err := NewLaravel(key).Cipher("AES-128-GCM").Encode("", "value"); err != ErrLaravelCipher {
+		t.Errorf("Expected %v, got %v.", ErrLaravelCipher, err)
+	}
+	if _, err := NewLaravel([]byte("short")).Encode("", "value"); err != ErrLaravelCipher {
+		t.Errorf("Expected %v, got %v.", ErrLaravelCipher, err)
+	}
+}
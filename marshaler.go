@@ -0,0 +1,161 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Interfaces detected by Encode and Decode, in order of precedence. The
+// first interface implemented by a value is used to encode it; values that
+// implement none of the enabled interfaces are encoded by the serializer.
+//
+// An interface is only detected on types that implement it in both
+// directions, so that Encode and Decode agree. Only DetectCoder is enabled
+// by default; see SecureCookie.Detect. Note that gob itself honors
+// encoding.BinaryMarshaler and encoding.TextMarshaler, but wraps their
+// output: cookies holding such values that were encoded with DetectBinary
+// and DetectText disabled cannot be decoded with them enabled.
+const (
+	// DetectCoder detects the Coder interface. It is also implemented by
+	// the types generated by gogo/protobuf.
+	DetectCoder = 1 << iota
+	// DetectProto detects the methods generated for protocol buffers by
+	// vtprotobuf (MarshalVT and UnmarshalVT) and by golang/protobuf
+	// before the v2 API (XXX_Marshal and XXX_Unmarshal).
+	DetectProto
+	// DetectBinary detects encoding.BinaryMarshaler and
+	// encoding.BinaryUnmarshaler.
+	DetectBinary
+	// DetectText detects encoding.TextMarshaler and
+	// encoding.TextUnmarshaler.
+	DetectText
+)
+
+type vtMarshaler interface {
+	MarshalVT() ([]byte, error)
+}
+
+type vtUnmarshaler interface {
+	UnmarshalVT([]byte) error
+}
+
+type xxxMarshaler interface {
+	XXX_Marshal(b []byte, deterministic bool) ([]byte, error)
+}
+
+type xxxUnmarshaler interface {
+	XXX_Unmarshal([]byte) error
+}
+
+var (
+	vtMarshalerType     = reflect.TypeOf((*vtMarshaler)(nil)).Elem()
+	vtUnmarshalerType   = reflect.TypeOf((*vtUnmarshaler)(nil)).Elem()
+	xxxMarshalerType    = reflect.TypeOf((*xxxMarshaler)(nil)).Elem()
+	xxxUnmarshalerType  = reflect.TypeOf((*xxxUnmarshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// detectedInterface returns the first enabled interface, among those other
+// than Coder, that a type implements in both directions, or 0 if there is
+// none. Encode and Decode make the same choice for a type and a pointer to
+// it, so values round-trip.
+func detectedInterface(flags int, t reflect.Type) int {
+	if t == nil {
+		return 0
+	}
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PointerTo(t)
+	}
+	switch {
+	case flags&DetectProto != 0 && t.Implements(vtMarshalerType) && t.Implements(vtUnmarshalerType):
+		return DetectProto
+	case flags&DetectProto != 0 && t.Implements(xxxMarshalerType) && t.Implements(xxxUnmarshalerType):
+		return DetectProto
+	case flags&DetectBinary != 0 && t.Implements(binaryMarshalerType) && t.Implements(binaryUnmarshalType):
+		return DetectBinary
+	case flags&DetectText != 0 && t.Implements(textMarshalerType) && t.Implements(textUnmarshalerType):
+		return DetectText
+	}
+	return 0
+}
+
+// marshal serializes a value, with the first detected interface it
+// implements or with the serializer.
+func (s *SecureCookie) marshal(value interface{}) ([]byte, error) {
+	if s.detect&DetectCoder != 0 {
+		if enc, ok := value.(Coder); ok {
+			return enc.Marshal()
+		}
+	}
+	if s.detect&^DetectCoder == 0 {
+		return s.sz.Serialize(value)
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return s.sz.Serialize(value)
+	}
+	switch detectedInterface(s.detect, v.Type()) {
+	case DetectProto:
+		switch m := addressable(v).(type) {
+		case vtMarshaler:
+			return m.MarshalVT()
+		case xxxMarshaler:
+			return m.XXX_Marshal(nil, true)
+		}
+	case DetectBinary:
+		return addressable(v).(encoding.BinaryMarshaler).MarshalBinary()
+	case DetectText:
+		return addressable(v).(encoding.TextMarshaler).MarshalText()
+	}
+	return s.sz.Serialize(value)
+}
+
+// unmarshal deserializes a value, with the first detected interface it
+// implements or with the serializer.
+func (s *SecureCookie) unmarshal(b []byte, dst interface{}) error {
+	if s.detect&DetectCoder != 0 {
+		if dec, ok := dst.(Coder); ok {
+			return dec.Unmarshal(b)
+		}
+	}
+	if s.detect&^DetectCoder == 0 {
+		return s.sz.Deserialize(b, dst)
+	}
+	switch detectedInterface(s.detect, reflect.TypeOf(dst)) {
+	case DetectProto:
+		switch u := dst.(type) {
+		case vtUnmarshaler:
+			return u.UnmarshalVT(b)
+		case xxxUnmarshaler:
+			return u.XXX_Unmarshal(b)
+		}
+	case DetectBinary:
+		if u, ok := dst.(encoding.BinaryUnmarshaler); ok {
+			return u.UnmarshalBinary(b)
+		}
+	case DetectText:
+		if u, ok := dst.(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText(b)
+		}
+	}
+	return s.sz.Deserialize(b, dst)
+}
+
+// addressable returns a pointer to a copy of v if v is not a pointer, so
+// that methods with pointer receivers can be called.
+func addressable(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		return v.Interface()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
+}
@@ -0,0 +1,141 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+// multiMarshaler implements every detected interface, marking its output
+// with the method that produced it.
+type multiMarshaler struct {
+	Value string
+}
+
+func (m *multiMarshaler) MarshalVT() ([]byte, error)     { return []byte("vt:" + m.Value), nil }
+func (m *multiMarshaler) UnmarshalVT(b []byte) error     { m.Value = string(b); return nil }
+func (m multiMarshaler) MarshalBinary() ([]byte, error)  { return []byte("bin:" + m.Value), nil }
+func (m *multiMarshaler) UnmarshalBinary(b []byte) error { m.Value = string(b); return nil }
+func (m multiMarshaler) MarshalText() ([]byte, error)    { return []byte("text:" + m.Value), nil }
+func (m *multiMarshaler) UnmarshalText(b []byte) error   { m.Value = string(b); return nil }
+
+// textOnly implements encoding.TextMarshaler without its counterpart.
+type textOnly struct {
+	Value string
+}
+
+func (t textOnly) MarshalText() ([]byte, error) { return []byte("text:" + t.Value), nil }
+
+// payload returns the serialized payload of an unencrypted cookie value.
+func payload(t *testing.T, encoded string) string {
+	b, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := pipesplit(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := decode(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestDetectPrecedence(t *testing.T) {
+	for _, test := range []struct {
+		flags  int
+		prefix string
+	}{
+		{DetectCoder | DetectProto | DetectBinary | DetectText, "vt:"},
+		{DetectBinary | DetectText, "bin:"},
+		{DetectText, "text:"},
+	} {
+		s := New([]byte("12345"), nil).Detect(test.flags)
+		// Values and pointers are detected alike.
+		for _, value := range []interface{}{multiMarshaler{"a"}, &multiMarshaler{"a"}} {
+			encoded, err := s.Encode("sid", value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := payload(t, encoded); got != test.prefix+"a" {
+				t.Errorf("Expected %v, got %v.", test.prefix+"a", got)
+			}
+			var dst multiMarshaler
+			if err = s.Decode("sid", encoded, &dst); err != nil || dst.Value != test.prefix+"a" {
+				t.Errorf("Unexpected value %v (%v)", dst.Value, err)
+			}
+		}
+	}
+
+	// Without detection, and by default, gob encodes the value itself.
+	for _, s := range []*SecureCookie{
+		New([]byte("12345"), nil).Detect(0),
+		New([]byte("12345"), nil),
+	} {
+		encoded, err := s.Encode("sid", multiMarshaler{"a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := payload(t, encoded); strings.HasPrefix(got, "vt:") || got == "bin:a" || got == "text:a" {
+			t.Errorf("Unexpected payload %q", got)
+		}
+	}
+}
+
+func TestDetectOneWay(t *testing.T) {
+	s := New([]byte("12345"), nil).SetSerializer(NewJSONSerializer())
+	encoded, err := s.Encode("sid", textOnly{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The JSON serializer calls MarshalText itself and quotes the result.
+	if got := payload(t, encoded); got != `"text:a"` {
+		t.Errorf("Expected %v, got %v.", `"text:a"`, got)
+	}
+}
+
+func TestDetectTime(t *testing.T) {
+	src := time.Date(2020, 9, 13, 12, 26, 40, 5, time.UTC)
+	binary, _ := src.MarshalBinary()
+	for _, test := range []struct {
+		codec  *SecureCookie
+		binary bool
+	}{
+		// By default, gob encodes times as it did before detection existed.
+		{New([]byte("12345"), nil), false},
+		{New([]byte("12345"), nil).Detect(DetectCoder | DetectBinary), true},
+	} {
+		encoded, err := test.codec.Encode("sid", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := payload(t, encoded) == string(binary); got != test.binary {
+			t.Errorf("Expected binary encoding %v, got %v.", test.binary, got)
+		}
+		var dst time.Time
+		if err = test.codec.Decode("sid", encoded, &dst); err != nil || !dst.Equal(src) {
+			t.Errorf("Expected %v, got %v (%v).", src, dst, err)
+		}
+	}
+}
+
+func TestDetectNil(t *testing.T) {
+	for _, flags := range []int{DetectCoder, DetectCoder | DetectProto | DetectBinary | DetectText} {
+		s := New([]byte("12345"), nil).Detect(flags)
+		// Gob refuses nil values; detection must not panic on them.
+		if _, err := s.Encode("sid", nil); err == nil {
+			t.Errorf("Expected an error encoding nil with flags %v.", flags)
+		}
+		if _, err := s.Explain("sid", nil); err == nil {
+			t.Errorf("Expected an error explaining nil with flags %v.", flags)
+		}
+	}
+}
@@ -0,0 +1,928 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMsgpackInvalid = errors.New("securecookie: invalid msgpack data")
+	ErrMsgpackDepth   = errors.New("securecookie: msgpack value exceeds the maximum depth")
+)
+
+// msgpackTimestamp is the extension type of MessagePack timestamps.
+const msgpackTimestamp = -1
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// MsgpackSerializer encodes cookie values using MessagePack, a compact
+// binary format with implementations in most languages.
+//
+// Structs are encoded as maps keyed by field name. The name can be changed
+// with a `msgpack:"name"` field tag, and the "omitempty" option and the "-"
+// name work as they do for encoding/json. Embedded structs are inlined,
+// except time.Time, which is a field named "Time". Byte slices are encoded
+// as binary data and time.Time values with the timestamp extension type.
+//
+// Values decoded into an interface{} are nil, bool, int64, uint64, float32,
+// float64, string, []byte, time.Time, []interface{}, or
+// map[string]interface{} (map[interface{}]interface{} for maps with keys
+// that are not strings).
+//
+// It is safe for concurrent use and needs no registration of types.
+type MsgpackSerializer struct {
+	maxLength int
+	maxDepth  int
+}
+
+// NewMsgpackSerializer returns a new MsgpackSerializer.
+func NewMsgpackSerializer() *MsgpackSerializer {
+	return &MsgpackSerializer{
+		maxLength: 4096,
+		maxDepth:  32,
+	}
+}
+
+// MaxLength restricts the maximum length, in bytes, of values accepted by
+// Deserialize. Independently of it, the lengths announced by values are
+// checked against the length of the input before allocating memory.
+//
+// Default is 4096. Set it to 0 for no restriction.
+func (m *MsgpackSerializer) MaxLength(value int) *MsgpackSerializer {
+	m.maxLength = value
+	return m
+}
+
+// MaxDepth restricts the nesting depth of arrays and maps accepted by
+// Deserialize.
+//
+// Default is 32. Set it to 0 for no restriction.
+func (m *MsgpackSerializer) MaxDepth(value int) *MsgpackSerializer {
+	m.maxDepth = value
+	return m
+}
+
+// Serialize encodes a value using MessagePack.
+func (m *MsgpackSerializer) Serialize(src interface{}) ([]byte, error) {
+	e := &msgpackEncoder{}
+	if err := e.encode(reflect.ValueOf(src)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Deserialize decodes a value using MessagePack. The dst argument must be
+// a pointer.
+func (m *MsgpackSerializer) Deserialize(src []byte, dst interface{}) error {
+	if m.maxLength != 0 && len(src) > m.maxLength {
+		return ErrTooLong
+	}
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("securecookie: msgpack destination must be a non-nil pointer")
+	}
+	d := &msgpackDecoder{buf: src, maxDepth: m.maxDepth}
+	if err := d.decode(v.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return ErrMsgpackInvalid
+	}
+	return nil
+}
+
+// Fields ---------------------------------------------------------------------
+
+// msgpackField describes how a struct field is encoded.
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var msgpackFieldCache sync.Map // map[reflect.Type][]msgpackField
+
+// msgpackFields returns the encoded fields of a struct type.
+func msgpackFields(t reflect.Type) []msgpackField {
+	if f, ok := msgpackFieldCache.Load(t); ok {
+		return f.([]msgpackField)
+	}
+	var fields []msgpackField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("msgpack")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct && !hasOwnEncoding(sf.Type) {
+			for _, f := range msgpackFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, msgpackField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	msgpackFieldCache.Store(t, fields)
+	return fields
+}
+
+// hasOwnEncoding reports whether a struct type is encoded as a whole rather
+// than field by field, so that embedding it does not inline its fields.
+func hasOwnEncoding(t reflect.Type) bool {
+	return t == timeType
+}
+
+// isEmptyValue reports whether a value is empty, as defined for the
+// omitempty option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Encoding -------------------------------------------------------------------
+
+// msgpackEncoder appends MessagePack values to a buffer.
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	if v.Type() == timeType {
+		e.writeTime(v.Interface().(time.Time))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			e.writeBytes(b)
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		keys := v.MapKeys()
+		if v.Type().Key().Kind() == reflect.String {
+			// Sort string keys so that encoding is deterministic.
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		}
+		e.writeHeader(0x80, 0xde, len(keys))
+		for _, k := range keys {
+			if err := e.encode(k); err != nil {
+				return err
+			}
+			if err := e.encode(v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := msgpackFields(v.Type())
+		values := make([]reflect.Value, 0, len(fields))
+		names := make([]string, 0, len(fields))
+		for _, f := range fields {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			names = append(names, f.name)
+			values = append(values, fv)
+		}
+		e.writeHeader(0x80, 0xde, len(values))
+		for i, fv := range values {
+			e.writeString(names[i])
+			if err := e.encode(fv); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("securecookie: msgpack cannot encode %v", v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value) error {
+	e.writeHeader(0x90, 0xdc, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHeader writes the header of an array or a map: fix is the code of
+// the fixed size form and code the one of the 16 bit form, followed by the
+// 32 bit form.
+func (e *msgpackEncoder) writeHeader(fix, code byte, n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, code)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, code+1)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) writeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.writeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+func (e *msgpackEncoder) writeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) writeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// writeTime writes a time with the smallest form of the timestamp extension
+// type.
+func (e *msgpackEncoder) writeTime(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec <= math.MaxUint32 && nsec == 0:
+		e.buf = append(e.buf, 0xd6, 0xff)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(sec))
+	case sec >= 0 && sec < 1<<34:
+		e.buf = append(e.buf, 0xd7, 0xff)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, nsec<<34|uint64(sec))
+	default:
+		e.buf = append(e.buf, 0xc7, 12, 0xff)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(nsec))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+	}
+}
+
+// Decoding -------------------------------------------------------------------
+
+// msgpackDecoder reads MessagePack values from a buffer.
+type msgpackDecoder struct {
+	buf      []byte
+	pos      int
+	depth    int
+	maxDepth int
+}
+
+// read returns the next n bytes of the buffer.
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, ErrMsgpackInvalid
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// readCode returns the next format code.
+func (d *msgpackDecoder) readCode() (byte, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readLength reads a length of size bytes following a format code.
+func (d *msgpackDecoder) readLength(size int) (int, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	}
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// enter increments the nesting depth and checks that n elements of at least
+// one byte each fit in the rest of the buffer.
+func (d *msgpackDecoder) enter(n int) error {
+	if d.depth++; d.maxDepth != 0 && d.depth > d.maxDepth {
+		return ErrMsgpackDepth
+	}
+	if n > len(d.buf)-d.pos {
+		return ErrMsgpackInvalid
+	}
+	return nil
+}
+
+// decodeValue decodes any value into the types documented for
+// MsgpackSerializer.
+func (d *msgpackDecoder) decodeValue() (interface{}, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0xa0 && c <= 0xbf:
+		b, err := d.read(int(c & 0x1f))
+		return string(b), err
+	case c >= 0x90 && c <= 0x9f:
+		return d.decodeArray(int(c & 0x0f))
+	case c >= 0x80 && c <= 0x8f:
+		return d.decodeMap(int(c & 0x0f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := d.read(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return beUint(b), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		b, err := d.read(1 << (c - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		return beInt(b), nil
+	case 0xca:
+		b, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case 0xcb:
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(n)
+		return string(b), err
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xdc, 0xdd:
+		n, err := d.readLength(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.readLength(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xc7, 0xc8, 0xc9:
+		d.pos--
+		return d.readTime()
+	}
+	return nil, ErrMsgpackInvalid
+}
+
+func (d *msgpackDecoder) decodeArray(n int) (interface{}, error) {
+	if err := d.enter(n); err != nil {
+		return nil, err
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		var err error
+		if a[i], err = d.decodeValue(); err != nil {
+			return nil, err
+		}
+	}
+	d.depth--
+	return a, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int) (interface{}, error) {
+	if err := d.enter(2 * n); err != nil {
+		return nil, err
+	}
+	keys, values := make([]interface{}, n), make([]interface{}, n)
+	stringKeys := true
+	for i := 0; i < n; i++ {
+		var err error
+		if keys[i], err = d.decodeValue(); err != nil {
+			return nil, err
+		}
+		if values[i], err = d.decodeValue(); err != nil {
+			return nil, err
+		}
+		if _, ok := keys[i].(string); !ok {
+			stringKeys = false
+		}
+	}
+	d.depth--
+	if stringKeys {
+		m := make(map[string]interface{}, n)
+		for i, k := range keys {
+			m[k.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, ErrMsgpackInvalid
+		}
+		m[k] = values[i]
+	}
+	return m, nil
+}
+
+// readTime reads a value of the timestamp extension type.
+func (d *msgpackDecoder) readTime() (time.Time, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var n int
+	switch c {
+	case 0xd6:
+		n = 4
+	case 0xd7:
+		n = 8
+	case 0xc7:
+		if n, err = d.readLength(1); err != nil {
+			return time.Time{}, err
+		}
+	default:
+		return time.Time{}, ErrMsgpackInvalid
+	}
+	typ, err := d.readCode()
+	if err != nil || int8(typ) != msgpackTimestamp {
+		return time.Time{}, ErrMsgpackInvalid
+	}
+	b, err := d.read(n)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(b)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(b)
+		return time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(nsec)), nil
+	}
+	return time.Time{}, ErrMsgpackInvalid
+}
+
+// decode decodes a value into v, which must be settable.
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	if d.pos < len(d.buf) && d.buf[d.pos] == 0xc0 {
+		d.pos++
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := d.readTime()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			break
+		}
+		x, err := d.decodeValue()
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		n, err := d.readArrayLength()
+		if err != nil {
+			return err
+		}
+		if err = d.enter(n); err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			if err = d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		d.depth--
+		return nil
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			if len(b) != v.Len() {
+				return fmt.Errorf("securecookie: msgpack cannot decode %d bytes into %v", len(b), v.Type())
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		n, err := d.readArrayLength()
+		if err != nil {
+			return err
+		}
+		if n != v.Len() {
+			return fmt.Errorf("securecookie: msgpack cannot decode %d elements into %v", n, v.Type())
+		}
+		if err = d.enter(n); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err = d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		d.depth--
+		return nil
+	case reflect.Map:
+		n, err := d.readMapLength()
+		if err != nil {
+			return err
+		}
+		if err = d.enter(2 * n); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		}
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err = d.decode(key); err != nil {
+				return err
+			}
+			// Interface keys may hold values that cannot be map keys.
+			if !key.Comparable() {
+				return ErrMsgpackInvalid
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = d.decode(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+		d.depth--
+		return nil
+	case reflect.Struct:
+		n, err := d.readMapLength()
+		if err != nil {
+			return err
+		}
+		if err = d.enter(2 * n); err != nil {
+			return err
+		}
+		fields := msgpackFields(v.Type())
+		for i := 0; i < n; i++ {
+			name, err := d.readString()
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, f := range fields {
+				if f.name == name {
+					if err = d.decode(v.FieldByIndex(f.index)); err != nil {
+						return err
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				// Skip values of unknown fields.
+				if _, err = d.decodeValue(); err != nil {
+					return err
+				}
+			}
+		}
+		d.depth--
+		return nil
+	}
+	x, err := d.decodeValue()
+	if err != nil {
+		return err
+	}
+	return setScalar(v, x)
+}
+
+// setScalar stores a decoded scalar value in v, converting it to the type
+// of v when that is lossless.
+func setScalar(v reflect.Value, x interface{}) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		if b, ok := x.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch i := x.(type) {
+		case int64:
+			n = i
+		case uint64:
+			if i > math.MaxInt64 {
+				return fmt.Errorf("securecookie: value %d overflows %v", i, v.Type())
+			}
+			n = int64(i)
+		default:
+			return fmt.Errorf("securecookie: cannot decode %T into %v", x, v.Type())
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("securecookie: value %d overflows %v", n, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch i := x.(type) {
+		case uint64:
+			n = i
+		case int64:
+			if i < 0 {
+				return fmt.Errorf("securecookie: value %d overflows %v", i, v.Type())
+			}
+			n = uint64(i)
+		default:
+			return fmt.Errorf("securecookie: cannot decode %T into %v", x, v.Type())
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("securecookie: value %d overflows %v", n, v.Type())
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch f := x.(type) {
+		case float64:
+			v.SetFloat(f)
+			return nil
+		case float32:
+			v.SetFloat(float64(f))
+			return nil
+		case int64:
+			v.SetFloat(float64(f))
+			return nil
+		case uint64:
+			v.SetFloat(float64(f))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := x.(string); ok {
+			v.SetString(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("securecookie: cannot decode %T into %v", x, v.Type())
+}
+
+// readString reads a string, which may be encoded as binary data.
+func (d *msgpackDecoder) readString() (string, error) {
+	x, err := d.decodeValue()
+	if err != nil {
+		return "", err
+	}
+	switch s := x.(type) {
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	}
+	return "", ErrMsgpackInvalid
+}
+
+// readBytes reads binary data, which may be encoded as a string.
+func (d *msgpackDecoder) readBytes() ([]byte, error) {
+	x, err := d.decodeValue()
+	if err != nil {
+		return nil, err
+	}
+	switch b := x.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	}
+	return nil, ErrMsgpackInvalid
+}
+
+func (d *msgpackDecoder) readArrayLength() (int, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c >= 0x90 && c <= 0x9f:
+		return int(c & 0x0f), nil
+	case c == 0xdc || c == 0xdd:
+		return d.readLength(2 << (c - 0xdc))
+	}
+	return 0, ErrMsgpackInvalid
+}
+
+func (d *msgpackDecoder) readMapLength() (int, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c >= 0x80 && c <= 0x8f:
+		return int(c & 0x0f), nil
+	case c == 0xde || c == 0xdf:
+		return d.readLength(2 << (c - 0xde))
+	}
+	return 0, ErrMsgpackInvalid
+}
+
+// beUint returns the big endian unsigned integer stored in b.
+func beUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// beInt returns the big endian signed integer stored in b.
+func beInt(b []byte) int64 {
+	n := beUint(b)
+	shift := 64 - 8*uint(len(b))
+	return int64(n<<shift) >> shift
+}
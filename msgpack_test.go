@@ -0,0 +1,180 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type msgpackSession struct {
+	ID      int               `msgpack:"id"`
+	Name    string            `msgpack:"name"`
+	Admin   bool              `msgpack:"admin,omitempty"`
+	Token   []byte            `msgpack:"token"`
+	Expires time.Time         `msgpack:"expires"`
+	Roles   []string          `msgpack:"roles"`
+	Prefs   map[string]string `msgpack:"prefs"`
+	Secret  string            `msgpack:"-"`
+	Embedded
+}
+
+type Embedded struct {
+	Version uint8 `msgpack:"v"`
+}
+
+func TestMsgpackVectors(t *testing.T) {
+	m := NewMsgpackSerializer()
+	for _, test := range []struct {
+		value interface{}
+		hex   string
+	}{
+		// Example of the msgpack.org home page.
+		{map[string]interface{}{"compact": true, "schema": 0}, "82a7636f6d70616374c3a6736368656d6100"},
+		{[]interface{}{-1, -33, 128, 1 << 16, -1 << 40}, "95ffd0dfcc80ce00010000d3ffffff0000000000"},
+		{[]byte{1, 2}, "c4020102"},
+		{1.5, "cb3ff8000000000000"},
+		{float32(1.5), "ca3fc00000"},
+		{strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{time.Unix(1, 0), "d6ff00000001"},
+		{time.Unix(1, 2), "d7ff0000000800000001"},
+		{time.Unix(-1, 0), "c70cff00000000ffffffffffffffff"},
+	} {
+		b, err := m.Serialize(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(b); got != test.hex {
+			t.Errorf("%v: expected %v, got %v.", test.value, test.hex, got)
+		}
+	}
+}
+
+func TestMsgpackSerializer(t *testing.T) {
+	s := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewMsgpackSerializer())
+	src := &msgpackSession{
+		ID:       42,
+		Name:     "gopher",
+		Token:    []byte{0, 1, 2, 255},
+		Expires:  time.Unix(1600000000, 123456789),
+		Roles:    []string{"reader", "writer"},
+		Prefs:    map[string]string{"theme": "dark"},
+		Secret:   "not encoded",
+		Embedded: Embedded{Version: 3},
+	}
+	encoded, err := s.Encode("sid", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &msgpackSession{}
+	if err = s.Decode("sid", encoded, dst); err != nil {
+		t.Fatal(err)
+	}
+	if !dst.Expires.Equal(src.Expires) {
+		t.Errorf("Expected %v, got %v.", src.Expires, dst.Expires)
+	}
+	dst.Expires, src.Secret = src.Expires, ""
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("Expected %+v, got %+v.", src, dst)
+	}
+
+	var generic map[string]interface{}
+	if err = s.Decode("sid", encoded, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic["admin"]; ok {
+		t.Error("Expected admin to be omitted.")
+	}
+	if generic["id"] != int64(42) || generic["v"] != int64(3) || !bytes.Equal(generic["token"].([]byte), src.Token) {
+		t.Errorf("Unexpected value %v", generic)
+	}
+}
+
+func TestMsgpackEmbeddedTime(t *testing.T) {
+	// An embedded time.Time is a field named after its type, not inlined.
+	type stamped struct {
+		time.Time
+		ID int `msgpack:"id"`
+	}
+	m := NewMsgpackSerializer()
+	src := stamped{time.Unix(1600000000, 0), 42}
+	b, err := m.Serialize(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst stamped
+	if err = m.Deserialize(b, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if !dst.Time.Equal(src.Time) || dst.ID != 42 {
+		t.Errorf("Expected %v, got %v.", src, dst)
+	}
+	var generic map[string]interface{}
+	if err = m.Deserialize(b, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic["Time"].(time.Time); !ok {
+		t.Errorf("Unexpected value %v", generic)
+	}
+}
+
+func TestMsgpackLimits(t *testing.T) {
+	m := NewMsgpackSerializer()
+	var dst interface{}
+	deep := bytes.Repeat([]byte{0x91}, 33)
+	deep = append(deep, 0xc0)
+	if err := m.Deserialize(deep, &dst); err != ErrMsgpackDepth {
+		t.Errorf("Expected %v, got %v.", ErrMsgpackDepth, err)
+	}
+	if err := m.MaxDepth(0).Deserialize(deep, &dst); err != nil {
+		t.Error(err)
+	}
+	// An array announcing more elements than the input holds.
+	var list []int
+	if err := m.Deserialize([]byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01}, &list); err != ErrMsgpackInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMsgpackInvalid, err)
+	}
+	if err := m.MaxLength(4).Deserialize([]byte{0xc4, 0x03, 1, 2, 3}, &dst); err != ErrTooLong {
+		t.Errorf("Expected %v, got %v.", ErrTooLong, err)
+	}
+	var small int8
+	if err := m.Deserialize([]byte{0xcc, 0x80}, &small); err == nil {
+		t.Error("Expected failure decoding an overflowing integer.")
+	}
+	if err := m.Deserialize([]byte{0xc3, 0xc3}, &dst); err != ErrMsgpackInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMsgpackInvalid, err)
+	}
+	// A map with an array key.
+	var keyed map[interface{}]interface{}
+	if err := m.Deserialize([]byte{0x81, 0x91, 0x01, 0x01}, &keyed); err != ErrMsgpackInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMsgpackInvalid, err)
+	}
+}
+
+func BenchmarkRoundtripMsgpack(b *testing.B) {
+	cook := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewMsgpackSerializer())
+
+	src := &FooBar{42, "bar"}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	var err error
+	var val string
+	for i := 0; i < b.N; i++ {
+		val, err = cook.Encode("sid", src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = cook.Decode("sid", val, src)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"net/url"
+	"strings"
+)
+
+// KeygripSuffix is appended to a cookie name to form the name of its
+// signature cookie.
+const KeygripSuffix = ".sig"
+
+// CookieSignature encodes and decodes values in the format of Express
+// signed cookies: "s:" followed by the value signed with cookie-signature.
+//
+// Strings, byte slices and Coder values are stored as is; other values are
+// stored as "j:" followed by their JSON encoding, as Express does. Encoded
+// values are URL escaped like the cookie module does. Signed cookies carry
+// no timestamp, so expiry is left to the cookie attributes. The cookie name
+// is not part of the value.
+type CookieSignature struct {
+	secrets   [][]byte
+	maxLength int
+	err       error
+}
+
+// NewCookieSignature returns a new CookieSignature codec.
+//
+// At least one secret is required. The first secret is used to sign values
+// and all of them are tried to verify values, as done by cookie-parser,
+// which allows secret rotation.
+func NewCookieSignature(secrets ...[]byte) *CookieSignature {
+	c := &CookieSignature{
+		secrets:   secrets,
+		maxLength: 4096,
+	}
+	if len(secrets) == 0 {
+		c.err = ErrHashKeyNotSet
+	}
+	return c
+}
+
+// MaxLength restricts the maximum length, in bytes, for the cookie value.
+//
+// Default is 4096.
+func (c *CookieSignature) MaxLength(value int) *CookieSignature {
+	c.maxLength = value
+	return c
+}
+
+// Encode encodes a value as an Express signed cookie.
+//
+// The name argument is ignored.
+func (c *CookieSignature) Encode(name string, value interface{}) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	b, err := marshalBytes(value)
+	if err == ErrUnsupportedValue {
+		if b, err = json.Marshal(value); err == nil {
+			b = append([]byte("j:"), b...)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	val := string(b)
+	out := encodeURIComponent("s:" + val + "." + c.signature(c.secrets[0], val))
+	if c.maxLength != 0 && len(out) > c.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes an Express signed cookie.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (c *CookieSignature) Decode(name, value string, dst interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.maxLength != 0 && len(value) > c.maxLength {
+		return ErrTooLong
+	}
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(value, "s:") {
+		return ErrMacInvalid
+	}
+	i := strings.LastIndexByte(value, '.')
+	if i == -1 {
+		return ErrMacInvalid
+	}
+	val, sig := value[2:i], value[i+1:]
+	valid := false
+	for _, secret := range c.secrets {
+		if hmac.Equal([]byte(sig), []byte(c.signature(secret, val))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrMacInvalid
+	}
+	if strings.HasPrefix(val, "j:") {
+		if dec, ok := dst.(Coder); ok {
+			return dec.Unmarshal([]byte(val[2:]))
+		}
+		return json.Unmarshal([]byte(val[2:]), dst)
+	}
+	return unmarshalBytes([]byte(val), dst)
+}
+
+// signature returns the signature of a value, as computed by
+// cookie-signature.
+func (c *CookieSignature) signature(secret []byte, value string) string {
+	mac := createMac(hmac.New(sha256.New, secret), []byte(value))
+	return base64.RawStdEncoding.EncodeToString(mac)
+}
+
+// Keygrip signs and verifies cookies in the format of keygrip, as used by
+// the cookies module of Koa: the signature of a cookie is stored in a
+// separate cookie, named after the first one with KeygripSuffix appended.
+//
+// Because the value and its signature live in two cookies, Keygrip does not
+// implement Codec. Use SignCookie when setting a cookie and VerifyCookie
+// when reading it.
+type Keygrip struct {
+	keys     [][]byte
+	hashFunc func() hash.Hash
+}
+
+// NewKeygrip returns a new Keygrip.
+//
+// The first key is used to sign values and all of them are tried, in order,
+// to verify values, which allows key rotation.
+func NewKeygrip(keys ...[]byte) *Keygrip {
+	return &Keygrip{
+		keys:     keys,
+		hashFunc: sha1.New,
+	}
+}
+
+// HashFunc sets the hash function used to create the HMAC.
+//
+// Default is crypto/sha1.New.
+func (k *Keygrip) HashFunc(f func() hash.Hash) *Keygrip {
+	k.hashFunc = f
+	return k
+}
+
+// Sign returns the signature of data with the first key.
+func (k *Keygrip) Sign(data string) (string, error) {
+	if len(k.keys) == 0 {
+		return "", ErrHashKeyNotSet
+	}
+	return k.sign(k.keys[0], data), nil
+}
+
+// Index returns the index of the key that produced digest for data, or -1
+// if no key did.
+func (k *Keygrip) Index(data, digest string) int {
+	for i, key := range k.keys {
+		if hmac.Equal([]byte(digest), []byte(k.sign(key, data))) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SignCookie returns the value of the signature cookie for a cookie.
+func (k *Keygrip) SignCookie(name, value string) (string, error) {
+	return k.Sign(name + "=" + value)
+}
+
+// VerifyCookie verifies a cookie against the value of its signature cookie.
+//
+// It returns the index of the key that signed the cookie. A positive index
+// means the cookie was signed with an older key and its signature should be
+// refreshed with SignCookie.
+func (k *Keygrip) VerifyCookie(name, value, sig string) (int, error) {
+	if len(k.keys) == 0 {
+		return -1, ErrHashKeyNotSet
+	}
+	i := k.Index(name+"="+value, sig)
+	if i == -1 {
+		return -1, ErrMacInvalid
+	}
+	return i, nil
+}
+
+// sign returns the signature of data with the given key.
+func (k *Keygrip) sign(key []byte, data string) string {
+	mac := createMac(hmac.New(k.hashFunc, key), []byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac)
+}
+
+// encodeURIComponent escapes a value so that it can be decoded by the
+// JavaScript decodeURIComponent function.
+func encodeURIComponent(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
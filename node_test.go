@@ -0,0 +1,80 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"testing"
+)
+
+func TestCookieSignature(t *testing.T) {
+	// Example from the cookie-signature tests.
+	c := NewCookieSignature([]byte("tobiiscool"))
+	if got, want := c.signature([]byte("tobiiscool"), "hello"), "DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QI"; got != want {
+		t.Errorf("Expected %v, got %v.", want, got)
+	}
+	var dst string
+	if err := c.Decode("", "s:hello.DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QI", &dst); err != nil || dst != "hello" {
+		t.Errorf("Unexpected value %q (%v)", dst, err)
+	}
+	if err := c.Decode("", "hello.DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QI", &dst); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+
+	// Set by res.cookie("sid", {user: "alice", n: 1}, {signed: true}).
+	express := "s%3Aj%3A%7B%22user%22%3A%22alice%22%2C%22n%22%3A1%7D.C7Mov%2Bj7yoBQaT96av2yaaqc%2FrVg3Z0bZ80wjg7If9E"
+	rotated := NewCookieSignature([]byte("new secret"), []byte("keyboard cat"))
+	type user struct {
+		User string `json:"user"`
+		N    int    `json:"n"`
+	}
+	var obj user
+	if err := rotated.Decode("sid", express, &obj); err != nil || obj.User != "alice" || obj.N != 1 {
+		t.Errorf("Unexpected value %+v (%v)", obj, err)
+	}
+	encoded, err := NewCookieSignature([]byte("keyboard cat")).Encode("sid", user{"alice", 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded != express {
+		t.Errorf("Expected %v, got %v.", express, encoded)
+	}
+	if err = NewCookieSignature([]byte("new secret")).Decode("sid", express, &obj); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+}
+
+func TestKeygrip(t *testing.T) {
+	// Signatures computed by keygrip for the koa.sess cookie.
+	const (
+		value  = "eyJ2aWV3cyI6M30="
+		oldSig = "nb25MBmYx9IlZeW-OVp5Kz4uNFM"
+		newSig = "z335muuUVJ4ojv54Fi7r6vjRwZo"
+	)
+	k := NewKeygrip([]byte("new key"), []byte("old key"))
+	if sig, err := k.SignCookie("koa.sess", value); err != nil || sig != newSig {
+		t.Errorf("Expected %v, got %v (%v).", newSig, sig, err)
+	}
+	tests := []struct {
+		Name  string
+		Sig   string
+		Index int
+		Err   error
+	}{
+		{"koa.sess", newSig, 0, nil},
+		{"koa.sess", oldSig, 1, nil},
+		{"koa.sess", "bogus", -1, ErrMacInvalid},
+		{"other", newSig, -1, ErrMacInvalid},
+	}
+	for _, test := range tests {
+		i, err := k.VerifyCookie(test.Name, value, test.Sig)
+		if i != test.Index || err != test.Err {
+			t.Errorf("%s %s: expected %d, %v; got %d, %v", test.Name, test.Sig, test.Index, test.Err, i, err)
+		}
+	}
+	if _, err := NewKeygrip().SignCookie("a", "b"); err != ErrHashKeyNotSet {
+		t.Errorf("Expected %v, got %v.", ErrHashKeyNotSet, err)
+	}
+}
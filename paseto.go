@@ -0,0 +1,391 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	pasetoLocalHeader  = "v4.local."
+	pasetoPublicHeader = "v4.public."
+	pasetoNonceSize    = 32
+	pasetoTagSize      = 32
+)
+
+var (
+	ErrPasetoHeader = errors.New("securecookie: invalid paseto header")
+	ErrPasetoFooter = errors.New("securecookie: invalid paseto footer")
+	ErrPasetoClaims = errors.New("securecookie: invalid paseto claims")
+	ErrNoSigningKey = errors.New("securecookie: no signing key set")
+)
+
+// PasetoLocal encodes and decodes values as PASETO v4.local tokens.
+//
+// The format is described at https://github.com/paseto-standard/paseto-spec.
+// Values are encoded as JSON, unless they implement Coder. When the value
+// encodes to a JSON object, the "iat" claim is added, as are the "nbf" and
+// "exp" claims when MinAge and MaxAge are set. On decoding, the "exp", "nbf"
+// and "iat" claims are validated and MinAge and MaxAge are applied to "iat".
+// Other values, like strings, and values that implement Coder carry no
+// claims, so MinAge and MaxAge do not apply to them. The cookie name is not
+// part of the token.
+type PasetoLocal struct {
+	key       []byte
+	footer    []byte
+	implicit  []byte
+	maxLength int
+	maxAge    int64
+	minAge    int64
+	err       error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewPasetoLocal returns a new PASETO v4.local codec.
+//
+// key is the 32 byte symmetric key.
+func NewPasetoLocal(key []byte) *PasetoLocal {
+	p := &PasetoLocal{
+		key:       key,
+		maxAge:    86400 * 30,
+		maxLength: 4096,
+	}
+	if len(key) != 32 {
+		p.err = errors.New("securecookie: paseto key must be 32 bytes")
+	}
+	return p
+}
+
+// Footer sets the footer appended to encoded tokens. Decoded tokens must
+// carry the same footer.
+//
+// Default is no footer.
+func (p *PasetoLocal) Footer(footer []byte) *PasetoLocal {
+	p.footer = footer
+	return p
+}
+
+// ImplicitAssertion sets the implicit assertion authenticated with the
+// token but not stored in it.
+//
+// Default is empty.
+func (p *PasetoLocal) ImplicitAssertion(implicit []byte) *PasetoLocal {
+	p.implicit = implicit
+	return p
+}
+
+// MaxLength restricts the maximum length, in bytes, for the token.
+//
+// Default is 4096.
+func (p *PasetoLocal) MaxLength(value int) *PasetoLocal {
+	p.maxLength = value
+	return p
+}
+
+// MaxAge restricts the maximum age, in seconds, for the token.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (p *PasetoLocal) MaxAge(value int) *PasetoLocal {
+	p.maxAge = int64(value)
+	return p
+}
+
+// MinAge restricts the minimum age, in seconds, for the token.
+//
+// Default is 0 (no restriction).
+func (p *PasetoLocal) MinAge(value int) *PasetoLocal {
+	p.minAge = int64(value)
+	return p
+}
+
+// Encode encodes a value as a PASETO v4.local token.
+//
+// The name argument is ignored.
+func (p *PasetoLocal) Encode(name string, value interface{}) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	payload, err := pasetoClaims.marshal(value, now(p.timeFunc), p.minAge, p.maxAge)
+	if err != nil {
+		return "", err
+	}
+	nonce := GenerateRandomKey(pasetoNonceSize)
+	if nonce == nil {
+		return "", errors.New("securecookie: failed to generate random nonce")
+	}
+	out, err := p.seal(payload, nonce)
+	if err != nil {
+		return "", err
+	}
+	if p.maxLength != 0 && len(out) > p.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a PASETO v4.local token.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (p *PasetoLocal) Decode(name, value string, dst interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.maxLength != 0 && len(value) > p.maxLength {
+		return ErrTooLong
+	}
+	payload, err := p.open(value)
+	if err != nil {
+		return err
+	}
+	return pasetoClaims.unmarshal(payload, dst, now(p.timeFunc), p.minAge, p.maxAge)
+}
+
+// seal encrypts a payload with the given nonce.
+func (p *PasetoLocal) seal(payload, nonce []byte) (string, error) {
+	ek, n2, ak, err := p.splitKey(nonce)
+	if err != nil {
+		return "", err
+	}
+	c := make([]byte, len(payload))
+	stream, err := chacha20.NewUnauthenticatedCipher(ek, n2)
+	if err != nil {
+		return "", err
+	}
+	stream.XORKeyStream(c, payload)
+	mac, _ := blake2b.New256(ak)
+	tag := createMac(mac, pae([]byte(pasetoLocalHeader), nonce, c, p.footer, p.implicit))
+	body := make([]byte, 0, len(nonce)+len(c)+len(tag))
+	body = append(append(append(body, nonce...), c...), tag...)
+	return pasetoFormat(pasetoLocalHeader, body, p.footer), nil
+}
+
+// open verifies and decrypts a token.
+func (p *PasetoLocal) open(token string) ([]byte, error) {
+	body, err := pasetoParse(token, pasetoLocalHeader, p.footer)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < pasetoNonceSize+pasetoTagSize {
+		return nil, ErrMacInvalid
+	}
+	nonce := body[:pasetoNonceSize]
+	c := body[pasetoNonceSize : len(body)-pasetoTagSize]
+	ek, n2, ak, err := p.splitKey(nonce)
+	if err != nil {
+		return nil, err
+	}
+	mac, _ := blake2b.New256(ak)
+	preAuth := pae([]byte(pasetoLocalHeader), nonce, c, p.footer, p.implicit)
+	if err = verifyMac(mac, preAuth, body[len(body)-pasetoTagSize:]); err != nil {
+		return nil, err
+	}
+	stream, err := chacha20.NewUnauthenticatedCipher(ek, n2)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, len(c))
+	stream.XORKeyStream(payload, c)
+	return payload, nil
+}
+
+// splitKey derives the encryption key, the XChaCha20 nonce and the
+// authentication key for a token nonce.
+func (p *PasetoLocal) splitKey(nonce []byte) (ek, n2, ak []byte, err error) {
+	h, err := blake2b.New(56, p.key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tmp := createMac(h, append([]byte("paseto-encryption-key"), nonce...))
+	if h, err = blake2b.New256(p.key); err != nil {
+		return nil, nil, nil, err
+	}
+	ak = createMac(h, append([]byte("paseto-auth-key-for-aead"), nonce...))
+	return tmp[:32], tmp[32:], ak, nil
+}
+
+// PasetoPublic encodes and decodes values as PASETO v4.public tokens.
+//
+// Payloads and claims are handled as for PasetoLocal. Public tokens are
+// signed, not encrypted, so their payload is readable by anyone.
+type PasetoPublic struct {
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+	footer     []byte
+	implicit   []byte
+	maxLength  int
+	maxAge     int64
+	minAge     int64
+	err        error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+// NewPasetoPublic returns a new PASETO v4.public codec.
+//
+// publicKey is required, used to verify tokens. privateKey is optional,
+// used to sign tokens; without it Encode returns ErrNoSigningKey.
+func NewPasetoPublic(publicKey ed25519.PublicKey, privateKey ed25519.PrivateKey) *PasetoPublic {
+	p := &PasetoPublic{
+		publicKey:  publicKey,
+		privateKey: privateKey,
+		maxAge:     86400 * 30,
+		maxLength:  4096,
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		p.err = errors.New("securecookie: invalid ed25519 public key")
+	} else if privateKey != nil && len(privateKey) != ed25519.PrivateKeySize {
+		p.err = errors.New("securecookie: invalid ed25519 private key")
+	}
+	return p
+}
+
+// Footer sets the footer appended to encoded tokens. Decoded tokens must
+// carry the same footer.
+//
+// Default is no footer.
+func (p *PasetoPublic) Footer(footer []byte) *PasetoPublic {
+	p.footer = footer
+	return p
+}
+
+// ImplicitAssertion sets the implicit assertion authenticated with the
+// token but not stored in it.
+//
+// Default is empty.
+func (p *PasetoPublic) ImplicitAssertion(implicit []byte) *PasetoPublic {
+	p.implicit = implicit
+	return p
+}
+
+// MaxLength restricts the maximum length, in bytes, for the token.
+//
+// Default is 4096.
+func (p *PasetoPublic) MaxLength(value int) *PasetoPublic {
+	p.maxLength = value
+	return p
+}
+
+// MaxAge restricts the maximum age, in seconds, for the token.
+//
+// Default is 86400 * 30. Set it to 0 for no restriction.
+func (p *PasetoPublic) MaxAge(value int) *PasetoPublic {
+	p.maxAge = int64(value)
+	return p
+}
+
+// MinAge restricts the minimum age, in seconds, for the token.
+//
+// Default is 0 (no restriction).
+func (p *PasetoPublic) MinAge(value int) *PasetoPublic {
+	p.minAge = int64(value)
+	return p
+}
+
+// Encode encodes a value as a PASETO v4.public token.
+//
+// The name argument is ignored.
+func (p *PasetoPublic) Encode(name string, value interface{}) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	if p.privateKey == nil {
+		return "", ErrNoSigningKey
+	}
+	payload, err := pasetoClaims.marshal(value, now(p.timeFunc), p.minAge, p.maxAge)
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(p.privateKey, pae([]byte(pasetoPublicHeader), payload, p.footer, p.implicit))
+	out := pasetoFormat(pasetoPublicHeader, append(payload, sig...), p.footer)
+	if p.maxLength != 0 && len(out) > p.maxLength {
+		return "", ErrTooLong
+	}
+	return out, nil
+}
+
+// Decode decodes a PASETO v4.public token.
+//
+// The name argument is ignored. The dst argument must be a pointer.
+func (p *PasetoPublic) Decode(name, value string, dst interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.maxLength != 0 && len(value) > p.maxLength {
+		return ErrTooLong
+	}
+	body, err := pasetoParse(value, pasetoPublicHeader, p.footer)
+	if err != nil {
+		return err
+	}
+	if len(body) < ed25519.SignatureSize {
+		return ErrMacInvalid
+	}
+	payload, sig := body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(p.publicKey, pae([]byte(pasetoPublicHeader), payload, p.footer, p.implicit), sig) {
+		return ErrMacInvalid
+	}
+	return pasetoClaims.unmarshal(payload, dst, now(p.timeFunc), p.minAge, p.maxAge)
+}
+
+// Helpers --------------------------------------------------------------------
+
+// pae returns the pre-authentication encoding of the given pieces.
+func pae(pieces ...[]byte) []byte {
+	n := 8
+	for _, piece := range pieces {
+		n += 8 + len(piece)
+	}
+	out := make([]byte, 8, n)
+	binary.LittleEndian.PutUint64(out, uint64(len(pieces)))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece))&^(1<<63))
+		out = append(out, piece...)
+	}
+	return out
+}
+
+// pasetoFormat assembles a token from its header, body and optional footer.
+func pasetoFormat(header string, body, footer []byte) string {
+	out := header + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) > 0 {
+		out += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return out
+}
+
+// pasetoParse checks the header and footer of a token and returns its
+// decoded body.
+func pasetoParse(token, header string, footer []byte) ([]byte, error) {
+	if !strings.HasPrefix(token, header) {
+		return nil, ErrPasetoHeader
+	}
+	parts := strings.Split(token[len(header):], ".")
+	if len(parts) > 2 {
+		return nil, ErrPasetoHeader
+	}
+	var f []byte
+	if len(parts) == 2 {
+		var err error
+		if f, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	if subtle.ConstantTimeCompare(f, footer) != 1 {
+		return nil, ErrPasetoFooter
+	}
+	return base64.RawURLEncoding.DecodeString(parts[0])
+}
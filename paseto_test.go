@@ -0,0 +1,281 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Vectors from https://github.com/paseto-standard/test-vectors.
+var pasetoVectors = []struct {
+	Name     string
+	Token    string
+	Key      string
+	Nonce    string
+	Payload  string
+	Footer   string
+	Implicit string
+}{
+	{
+		"4-E-1",
+		"v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		`{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+		"",
+		"",
+	},
+	{
+		"4-E-2",
+		"v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvS2csCgglvpk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XIemu9chy3WVKvRBfg6t8wwYHK0ArLxxfZP73W_vfwt5A",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		`{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+		"",
+		"",
+	},
+	{
+		"4-E-3",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t6-tyebyWG6Ov7kKvBdkrrAJ837lKP3iDag2hzUPHuMKA",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+		"",
+		"",
+	},
+	{
+		"4-E-4",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t4gt6TiLm55vIH8c_lGxxZpE3AWlH4WTR0v45nsWoU3gQ",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+		"",
+		"",
+	},
+	{
+		"4-E-5",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t4x-RMNXtQNbz7FvFZ_G-lFpk5RG3EOrwDL6CgDqcerSQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+		`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`,
+		"",
+	},
+	{
+		"4-E-6",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t6pWSA5HX2wjb3P-xLQg5K5feUCX4P2fpVK3ZLWFbMSxQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+		`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`,
+		"",
+	},
+	{
+		"4-E-7",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t40KCCWLA7GYL9KFHzKlwY9_RnIfRrMQpueydLEAZGGcA.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+		`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`,
+		`{"test-vector":"4-E-7"}`,
+	},
+	{
+		"4-E-8",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t5uvqQbMGlLLNYBc7A6_x7oqnpUK5WLvj24eE4DVPDZjw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+		`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`,
+		`{"test-vector":"4-E-8"}`,
+	},
+	{
+		"4-E-9",
+		"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t6tybdlmnMwcDMw0YxA_gFSE_IUWl78aMtOepFYSWYfQA.YXJiaXRyYXJ5LXN0cmluZy10aGF0LWlzbid0LWpzb24",
+		"707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f",
+		"df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		`{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+		"arbitrary-string-that-isn't-json",
+		`{"test-vector":"4-E-9"}`,
+	},
+	{
+		"4-S-1",
+		"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+		"b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2",
+		"",
+		`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`,
+		"",
+		"",
+	},
+	{
+		"4-S-2",
+		"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9v3Jt8mx_TdM2ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx1HcO-SPo8FPp214HDw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		"b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2",
+		"",
+		`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`,
+		`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`,
+		"",
+	},
+	{
+		"4-S-3",
+		"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9NPWciuD3d0o5eXJXG5pJy-DiVEoyPYWs1YSTwWHNJq6DZD3je5gf-0M4JR9ipdUSJbIovzmBECeaWmaqcaP0DQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		"b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2",
+		"",
+		`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`,
+		`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`,
+		`{"test-vector":"4-S-3"}`,
+	},
+}
+
+// pasetoVectorTime is a time at which the test vectors have not expired.
+var pasetoVectorTime = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+func TestPasetoVectors(t *testing.T) {
+	for _, v := range pasetoVectors {
+		key, _ := hex.DecodeString(v.Key)
+		var codec Codec
+		if v.Nonce != "" {
+			p := NewPasetoLocal(key).Footer([]byte(v.Footer)).ImplicitAssertion([]byte(v.Implicit))
+			p.timeFunc = func() int64 { return pasetoVectorTime }
+			nonce, _ := hex.DecodeString(v.Nonce)
+			if got, err := p.seal([]byte(v.Payload), nonce); err != nil || got != v.Token {
+				t.Errorf("%s: expected %v, got %v (%v)", v.Name, v.Token, got, err)
+			}
+			codec = p
+		} else {
+			priv := ed25519.PrivateKey(key)
+			p := NewPasetoPublic(priv.Public().(ed25519.PublicKey), priv).Footer([]byte(v.Footer)).ImplicitAssertion([]byte(v.Implicit))
+			p.timeFunc = func() int64 { return pasetoVectorTime }
+			codec = p
+		}
+		var dst map[string]string
+		if err := codec.Decode("", v.Token, &dst); err != nil {
+			t.Errorf("%s: %v", v.Name, err)
+		} else if dst["exp"] != "2022-01-01T00:00:00+00:00" {
+			t.Errorf("%s: unexpected payload %v", v.Name, dst)
+		}
+	}
+}
+
+func TestPasetoVectorFailures(t *testing.T) {
+	// Failures in the spirit of the 4-F vectors, derived from the tokens
+	// above: the purpose or version of a token is not the expected one, or
+	// its encoding is padded.
+	key, _ := hex.DecodeString(pasetoVectors[0].Key)
+	local := NewPasetoLocal(key)
+	local.timeFunc = func() int64 { return pasetoVectorTime }
+	priv, _ := hex.DecodeString(pasetoVectors[9].Key)
+	public := NewPasetoPublic(ed25519.PrivateKey(priv).Public().(ed25519.PublicKey), nil)
+	public.timeFunc = func() int64 { return pasetoVectorTime }
+	tests := []struct {
+		Name  string
+		Codec Codec
+		Token string
+		Err   error
+	}{
+		{"local token, public key", public, pasetoVectors[0].Token, ErrPasetoHeader},
+		{"public token, local key", local, pasetoVectors[9].Token, ErrPasetoHeader},
+		{"other version", local, "v3" + pasetoVectors[0].Token[2:], ErrPasetoHeader},
+	}
+	for _, test := range tests {
+		var dst map[string]string
+		if err := test.Codec.Decode("", test.Token, &dst); err != test.Err {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Err, err)
+		}
+	}
+	token := pasetoVectors[4].Token
+	i := strings.LastIndex(token, ".")
+	var dst map[string]string
+	if err := local.Footer([]byte(pasetoVectors[4].Footer)).Decode("", token[:i]+"=="+token[i:], &dst); err == nil {
+		t.Errorf("padded: expected an error")
+	}
+}
+
+func TestPasetoClaims(t *testing.T) {
+	var ts int64 = 1600000000
+	p := NewPasetoLocal(GenerateRandomKey(32)).MaxAge(60)
+	p.timeFunc = func() int64 { return ts }
+	encoded, err := p.Encode("sid", map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst map[string]string
+	if err = p.Decode("sid", encoded, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst["foo"] != "bar" || dst["iat"] != "2020-09-13T12:26:40Z" || dst["exp"] != "2020-09-13T12:27:40Z" {
+		t.Errorf("Unexpected claims %v", dst)
+	}
+	p.timeFunc = func() int64 { return ts + 61 }
+	if err = p.Decode("sid", encoded, &dst); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+	p.timeFunc = func() int64 { return ts - 1 }
+	if err = p.Decode("sid", encoded, &dst); err != ErrTooNew {
+		t.Errorf("Expected %v, got %v.", ErrTooNew, err)
+	}
+}
+
+func TestPasetoUnclaimedValues(t *testing.T) {
+	// Values that are not JSON objects carry no claims, so they round-trip
+	// with the default MaxAge.
+	p := NewPasetoLocal(GenerateRandomKey(32))
+	encoded, err := p.Encode("sid", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst string
+	if err = p.Decode("sid", encoded, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst != "value" {
+		t.Errorf("Expected %v, got %v.", "value", dst)
+	}
+	encoded, err = p.Encode("sid", &TestCoder{Str: `{"n":1}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var coder TestCoder
+	if err = p.Decode("sid", encoded, &coder); err != nil {
+		t.Fatal(err)
+	}
+	if coder.Str != `{"n":1}` {
+		t.Errorf("Expected %v, got %v.", `{"n":1}`, coder.Str)
+	}
+}
+
+func TestPasetoAssertions(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(GenerateRandomKey(ed25519.SeedSize))
+	pub := priv.Public().(ed25519.PublicKey)
+	signer := NewPasetoPublic(pub, priv).Footer([]byte("kid-1")).ImplicitAssertion([]byte("sid"))
+	encoded, err := signer.Encode("sid", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Name  string
+		Codec Codec
+		Err   error
+	}{
+		{"verifier", NewPasetoPublic(pub, nil).Footer([]byte("kid-1")).ImplicitAssertion([]byte("sid")), nil},
+		{"wrong footer", NewPasetoPublic(pub, nil).Footer([]byte("kid-2")).ImplicitAssertion([]byte("sid")), ErrPasetoFooter},
+		{"no footer", NewPasetoPublic(pub, nil).ImplicitAssertion([]byte("sid")), ErrPasetoFooter},
+		{"wrong assertion", NewPasetoPublic(pub, nil).Footer([]byte("kid-1")), ErrMacInvalid},
+		{"wrong purpose", NewPasetoLocal(GenerateRandomKey(32)).Footer([]byte("kid-1")), ErrPasetoHeader},
+	}
+	for _, test := range tests {
+		var dst map[string]interface{}
+		if err := test.Codec.Decode("sid", encoded, &dst); err != test.Err {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Err, err)
+		}
+	}
+	if _, err = NewPasetoPublic(pub, nil).Encode("sid", "value"); err != ErrNoSigningKey {
+		t.Errorf("Expected %v, got %v.", ErrNoSigningKey, err)
+	}
+}
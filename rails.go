@@ -0,0 +1,385 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Salts used by Rails to derive the cookie keys from secret_key_base.
+const (
+	RailsSignedCookieSalt    = "signed cookie"
+	RailsEncryptedCookieSalt = "authenticated encrypted cookie"
+)
+
+var ErrRailsPurpose = errors.New("securecookie: rails message purpose does not match")
+
+// RailsKeyGenerator derives keys from a Rails secret_key_base in the same
+// way as ActiveSupport::KeyGenerator.
+type RailsKeyGenerator struct {
+	secret     string
+	iterations int
+	hashFunc   func() hash.Hash
+}
+
+// NewRailsKeyGenerator returns a new RailsKeyGenerator.
+//
+// The defaults match the key generator of a Rails 7 application: 1000
+// iterations of PBKDF2 with SHA-256.
+func NewRailsKeyGenerator(secretKeyBase string) *RailsKeyGenerator {
+	return &RailsKeyGenerator{
+		secret:     secretKeyBase,
+		iterations: 1000,
+		hashFunc:   sha256.New,
+	}
+}
+
+// Iterations sets the number of PBKDF2 iterations.
+//
+// Default is 1000.
+func (g *RailsKeyGenerator) Iterations(value int) *RailsKeyGenerator {
+	g.iterations = value
+	return g
+}
+
+// HashFunc sets the hash function used by PBKDF2. Applications created
+// before Rails 7 use crypto/sha1.New.
+//
+// Default is crypto/sha256.New.
+func (g *RailsKeyGenerator) HashFunc(f func() hash.Hash) *RailsKeyGenerator {
+	g.hashFunc = f
+	return g
+}
+
+// GenerateKey derives a key of the given length for a salt. It returns nil
+// if the key cannot be derived.
+func (g *RailsKeyGenerator) GenerateKey(salt string, length int) []byte {
+	key, err := pbkdf2.Key(g.hashFunc, g.secret, []byte(salt), g.iterations, length)
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// railsMetadata is the "_rails" envelope added to messages with a purpose
+// or an expiry.
+type railsMetadata struct {
+	Rails struct {
+		Message string          `json:"message,omitempty"`
+		Data    json.RawMessage `json:"data,omitempty"`
+		Exp     *string         `json:"exp"`
+		Pur     *string         `json:"pur"`
+	} `json:"_rails"`
+}
+
+// RailsVerifier encodes and decodes values in the format of Rails
+// ActiveSupport::MessageVerifier, as used for signed cookies.
+//
+// Values are encoded as JSON, unless they implement Coder. The cookie name
+// is stored as the "cookie.<name>" purpose and the expiry set by MaxAge is
+// stored in the message metadata, as done by the Rails cookie jar. Messages
+// without metadata carry no purpose, so they are only decoded with an empty
+// name.
+type RailsVerifier struct {
+	secret   []byte
+	hashFunc func() hash.Hash
+	railsMessage
+}
+
+// NewRailsVerifier returns a new RailsVerifier.
+//
+// secret is the signing key. For signed cookies, it is derived from
+// secret_key_base with RailsSignedCookieSalt and a length of 64 bytes.
+func NewRailsVerifier(secret []byte) *RailsVerifier {
+	v := &RailsVerifier{
+		secret:       secret,
+		hashFunc:     sha1.New,
+		railsMessage: newRailsMessage(),
+	}
+	if len(secret) == 0 {
+		v.err = ErrHashKeyNotSet
+	}
+	return v
+}
+
+// HashFunc sets the hash function used to create the HMAC. It corresponds
+// to the signed_cookie_digest setting of Rails.
+//
+// Default is crypto/sha1.New.
+func (v *RailsVerifier) HashFunc(f func() hash.Hash) *RailsVerifier {
+	v.hashFunc = f
+	return v
+}
+
+// MaxLength restricts the maximum length, in bytes, for the message.
+//
+// Default is 4096.
+func (v *RailsVerifier) MaxLength(value int) *RailsVerifier {
+	v.maxLength = value
+	return v
+}
+
+// MaxAge sets the expiry, in seconds, of encoded messages. Expired messages
+// are always rejected.
+//
+// Default is 86400 * 30. Set it to 0 for messages that do not expire.
+func (v *RailsVerifier) MaxAge(value int) *RailsVerifier {
+	v.maxAge = int64(value)
+	return v
+}
+
+// Encode encodes a value as a signed Rails message.
+func (v *RailsVerifier) Encode(name string, value interface{}) (string, error) {
+	if v.err != nil {
+		return "", v.err
+	}
+	b, err := v.wrap(name, value)
+	if err != nil {
+		return "", err
+	}
+	data := base64.StdEncoding.EncodeToString(b)
+	mac := createMac(hmac.New(v.hashFunc, v.secret), []byte(data))
+	return v.escape(data + "--" + hex.EncodeToString(mac))
+}
+
+// Decode decodes a signed Rails message. URL escaped values, as stored by
+// Rack, are accepted.
+func (v *RailsVerifier) Decode(name, value string, dst interface{}) error {
+	if v.err != nil {
+		return v.err
+	}
+	value, err := v.unescape(value)
+	if err != nil {
+		return err
+	}
+	i := strings.LastIndex(value, "--")
+	if i <= 0 {
+		return ErrMacInvalid
+	}
+	data := value[:i]
+	mac, err := hex.DecodeString(value[i+2:])
+	if err != nil {
+		return ErrMacInvalid
+	}
+	if err = verifyMac(hmac.New(v.hashFunc, v.secret), []byte(data), mac); err != nil {
+		return err
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+	return v.unwrap(name, b, dst)
+}
+
+// RailsEncryptor encodes and decodes values in the format of Rails
+// ActiveSupport::MessageEncryptor with AES-256-GCM, as used for encrypted
+// cookies.
+//
+// Values, purposes and expiry are handled as for RailsVerifier.
+type RailsEncryptor struct {
+	aead cipher.AEAD
+	railsMessage
+}
+
+// NewRailsEncryptor returns a new RailsEncryptor.
+//
+// secret is the 32 byte encryption key. For encrypted cookies, it is
+// derived from secret_key_base with RailsEncryptedCookieSalt.
+func NewRailsEncryptor(secret []byte) *RailsEncryptor {
+	e := &RailsEncryptor{railsMessage: newRailsMessage()}
+	if len(secret) != 32 {
+		e.err = errors.New("securecookie: rails encryption key must be 32 bytes")
+		return e
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		e.err = err
+		return e
+	}
+	e.aead, e.err = cipher.NewGCM(block)
+	return e
+}
+
+// MaxLength restricts the maximum length, in bytes, for the message.
+//
+// Default is 4096.
+func (e *RailsEncryptor) MaxLength(value int) *RailsEncryptor {
+	e.maxLength = value
+	return e
+}
+
+// MaxAge sets the expiry, in seconds, of encoded messages. Expired messages
+// are always rejected.
+//
+// Default is 86400 * 30. Set it to 0 for messages that do not expire.
+func (e *RailsEncryptor) MaxAge(value int) *RailsEncryptor {
+	e.maxAge = int64(value)
+	return e
+}
+
+// Encode encodes a value as an encrypted Rails message.
+func (e *RailsEncryptor) Encode(name string, value interface{}) (string, error) {
+	if e.err != nil {
+		return "", e.err
+	}
+	b, err := e.wrap(name, value)
+	if err != nil {
+		return "", err
+	}
+	iv := GenerateRandomKey(e.aead.NonceSize())
+	if iv == nil {
+		return "", errors.New("securecookie: failed to generate random iv")
+	}
+	sealed := e.aead.Seal(nil, iv, b, nil)
+	tagStart := len(sealed) - e.aead.Overhead()
+	return e.escape(base64.StdEncoding.EncodeToString(sealed[:tagStart]) + "--" +
+		base64.StdEncoding.EncodeToString(iv) + "--" +
+		base64.StdEncoding.EncodeToString(sealed[tagStart:]))
+}
+
+// Decode decodes an encrypted Rails message. URL escaped values, as stored
+// by Rack, are accepted.
+func (e *RailsEncryptor) Decode(name, value string, dst interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	value, err := e.unescape(value)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(value, "--")
+	if len(parts) != 3 {
+		return ErrMacInvalid
+	}
+	var ciphertext, iv, tag []byte
+	for i, dst := range []*[]byte{&ciphertext, &iv, &tag} {
+		if *dst, err = base64.StdEncoding.DecodeString(parts[i]); err != nil {
+			return ErrMacInvalid
+		}
+	}
+	if len(iv) != e.aead.NonceSize() || len(tag) != e.aead.Overhead() {
+		return ErrMacInvalid
+	}
+	b, err := e.aead.Open(nil, iv, append(ciphertext, tag...), nil)
+	if err != nil {
+		return ErrMacInvalid
+	}
+	return e.unwrap(name, b, dst)
+}
+
+// railsMessage holds the settings shared by RailsVerifier and
+// RailsEncryptor and handles the "_rails" metadata envelope.
+type railsMessage struct {
+	maxLength int
+	maxAge    int64
+	err       error
+	// For testing purposes, the function that returns the current timestamp.
+	// If not set, it will use time.Now().UTC().Unix().
+	timeFunc func() int64
+}
+
+func newRailsMessage() railsMessage {
+	return railsMessage{
+		maxAge:    86400 * 30,
+		maxLength: 4096,
+	}
+}
+
+// escape URL escapes an encoded message, as Rack does, and checks its length.
+func (m *railsMessage) escape(value string) (string, error) {
+	value = url.QueryEscape(value)
+	if m.maxLength != 0 && len(value) > m.maxLength {
+		return "", ErrTooLong
+	}
+	return value, nil
+}
+
+// unescape checks the length of a message and reverts URL escaping.
+func (m *railsMessage) unescape(value string) (string, error) {
+	if m.maxLength != 0 && len(value) > m.maxLength {
+		return "", ErrTooLong
+	}
+	if strings.IndexByte(value, '%') == -1 {
+		return value, nil
+	}
+	return url.QueryUnescape(value)
+}
+
+// wrap serializes a value and wraps it in the metadata envelope used by
+// Rails 5.2 to 7.0, which later versions also read.
+func (m *railsMessage) wrap(name string, value interface{}) ([]byte, error) {
+	b, err := marshalJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" && m.maxAge == 0 {
+		return b, nil
+	}
+	var meta railsMetadata
+	meta.Rails.Message = base64.StdEncoding.EncodeToString(b)
+	if name != "" {
+		purpose := "cookie." + name
+		meta.Rails.Pur = &purpose
+	}
+	if m.maxAge != 0 {
+		exp := time.Unix(now(m.timeFunc)+m.maxAge, 0).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+		meta.Rails.Exp = &exp
+	}
+	return json.Marshal(meta)
+}
+
+// unwrap checks the metadata envelope of a message, if any, and
+// deserializes its value into dst.
+func (m *railsMessage) unwrap(name string, b []byte, dst interface{}) error {
+	var meta railsMetadata
+	if json.Unmarshal(b, &meta) == nil && (meta.Rails.Message != "" || meta.Rails.Data != nil) {
+		var purpose, expected string
+		if meta.Rails.Pur != nil {
+			purpose = *meta.Rails.Pur
+		}
+		if name != "" {
+			expected = "cookie." + name
+		}
+		if purpose != expected {
+			return ErrRailsPurpose
+		}
+		if meta.Rails.Exp != nil {
+			exp, err := time.Parse(time.RFC3339, *meta.Rails.Exp)
+			if err != nil {
+				return ErrTimeInvalid
+			}
+			if now(m.timeFunc) >= exp.Unix() {
+				return ErrExpired
+			}
+		}
+		if meta.Rails.Data != nil {
+			// Rails 7.1 and later store the serialized value inline.
+			b = meta.Rails.Data
+		} else {
+			var err error
+			if b, err = base64.StdEncoding.DecodeString(meta.Rails.Message); err != nil {
+				return err
+			}
+		}
+	} else if name != "" {
+		// A message without metadata has no purpose.
+		return ErrRailsPurpose
+	}
+	return unmarshalJSON(b, dst)
+}
@@ -0,0 +1,112 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestRailsKeyGenerator(t *testing.T) {
+	// PBKDF2-HMAC-SHA1 vector from RFC 6070.
+	key := NewRailsKeyGenerator("password").Iterations(1).HashFunc(sha1.New).GenerateKey("salt", 20)
+	if got, want := hex.EncodeToString(key), "0c60c80f961f0e71f3a9b524af6012062fe037a6"; got != want {
+		t.Errorf("Expected %v, got %v.", want, got)
+	}
+	g := NewRailsKeyGenerator("secret_key_base")
+	if len(g.GenerateKey(RailsSignedCookieSalt, 64)) != 64 || len(g.GenerateKey(RailsEncryptedCookieSalt, 32)) != 32 {
+		t.Error("Unexpected key length.")
+	}
+}
+
+func TestRailsRoundtrip(t *testing.T) {
+	g := NewRailsKeyGenerator("secret_key_base")
+	codecs := []Codec{
+		NewRailsVerifier(g.GenerateKey(RailsSignedCookieSalt, 64)),
+		NewRailsEncryptor(g.GenerateKey(RailsEncryptedCookieSalt, 32)),
+	}
+	for _, c := range codecs {
+		encoded, err := c.Encode("session", map[string]interface{}{"user_id": 42})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst map[string]int
+		if err = c.Decode("session", encoded, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst["user_id"] != 42 {
+			t.Errorf("Unexpected value %v", dst)
+		}
+		if err = c.Decode("remember_me", encoded, &dst); err != ErrRailsPurpose {
+			t.Errorf("Expected %v, got %v.", ErrRailsPurpose, err)
+		}
+	}
+}
+
+func TestRailsExpiry(t *testing.T) {
+	var ts int64 = 1600000000
+	e := NewRailsEncryptor(GenerateRandomKey(32)).MaxAge(60)
+	e.timeFunc = func() int64 { return ts }
+	encoded, err := e.Encode("session", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst string
+	ts += 59
+	if err = e.Decode("session", encoded, &dst); err != nil || dst != "value" {
+		t.Errorf("Expected %v, got %v (%v).", "value", dst, err)
+	}
+	// Messages expire at their expiry time.
+	ts++
+	if err = e.Decode("session", encoded, &dst); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+}
+
+func TestRailsVerifierFormats(t *testing.T) {
+	secret := GenerateRandomKey(64)
+	sign := func(message string) string {
+		data := base64.StdEncoding.EncodeToString([]byte(message))
+		return data + "--" + hex.EncodeToString(createMac(hmac.New(sha1.New, secret), []byte(data)))
+	}
+	tests := []struct {
+		Name    string
+		Message string
+		Err     error
+	}{
+		{"legacy", `{"a":"b"}`, ErrRailsPurpose},
+		{"rails 6", `{"_rails":{"message":"eyJhIjoiYiJ9","exp":null,"pur":"cookie.sid"}}`, nil},
+		{"rails 7.1", `{"_rails":{"data":{"a":"b"},"pur":"cookie.sid"}}`, nil},
+		{"expired", `{"_rails":{"data":{"a":"b"},"exp":"2000-01-01T00:00:00.000Z","pur":"cookie.sid"}}`, ErrExpired},
+		{"no purpose", `{"_rails":{"data":{"a":"b"}}}`, ErrRailsPurpose},
+	}
+	v := NewRailsVerifier(secret)
+	for _, test := range tests {
+		var dst map[string]string
+		err := v.Decode("sid", sign(test.Message), &dst)
+		if err != test.Err {
+			t.Errorf("%s: expected %v, got %v", test.Name, test.Err, err)
+		} else if err == nil && dst["a"] != "b" {
+			t.Errorf("%s: unexpected value %v", test.Name, dst)
+		}
+	}
+	var dst map[string]string
+	if err := v.Decode("", sign(`{"a":"b"}`), &dst); err != nil || dst["a"] != "b" {
+		t.Errorf("legacy without name: unexpected value %v (%v)", dst, err)
+	}
+	escaped := strings.Replace(sign(`{"a":"b"}`), "=", "%3D", -1)
+	dst = nil
+	if err := v.Decode("", escaped, &dst); err != nil || dst["a"] != "b" {
+		t.Errorf("escaped: unexpected value %v (%v)", dst, err)
+	}
+	if err := v.Decode("sid", sign(`{"a":"b"}`)+"0", &dst); err != ErrMacInvalid {
+		t.Errorf("Expected %v, got %v.", ErrMacInvalid, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrSchemaVersion = errors.New("securecookie: unsupported schema version")
+
+// schemaMagic starts versioned payloads, followed by the schema version.
+// Serialized values never start with a zero byte followed by these bytes,
+// so payloads without it are read as version 0.
+var schemaMagic = []byte("\x00scv")
+
+// migration converts values of a schema version to the next one.
+type migration struct {
+	typ reflect.Type
+	fn  func(interface{}) (interface{}, error)
+}
+
+// SchemaVersion sets the schema version of encoded values. When it is set,
+// the version is stored with each value, and Decode migrates values encoded
+// with older versions using the functions registered with Migrate. Values
+// encoded without a version, before versions were set, have version 0.
+//
+// Default is 0, which stores no version. A codec with version 0 rejects
+// versioned values with ErrSchemaVersion.
+func (s *SecureCookie) SchemaVersion(version int) *SecureCookie {
+	if version < 0 {
+		s.err = ErrSchemaVersion
+	}
+	s.version = version
+	return s
+}
+
+// Migrate registers the migration of values encoded with schema version
+// from to version from+1. Version 0 holds the values encoded before a
+// schema version was set.
+//
+// The old argument is a value of the type encoded with version from; values
+// are decoded into a new one, so its type must be decodable by the
+// serializer. The fn function receives a pointer to the decoded value and
+// returns a pointer to its migrated value, which is either passed to the
+// migration of the next version, or stored in the Decode destination.
+func (s *SecureCookie) Migrate(from int, old interface{}, fn func(interface{}) (interface{}, error)) *SecureCookie {
+	t := reflect.TypeOf(old)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if from < 0 || t == nil || fn == nil {
+		s.err = ErrSchemaVersion
+		return s
+	}
+	if s.migrations == nil {
+		s.migrations = make(map[int]migration)
+	}
+	s.migrations[from] = migration{typ: t, fn: fn}
+	return s
+}
+
+// OnUpgrade sets a function called by Decode after it migrated a value from
+// an older schema version, with the cookie name and the Decode destination.
+// The value is decoded, and Decode returns no error; the cookie should be
+// encoded again, so that it is not migrated on every request.
+func (s *SecureCookie) OnUpgrade(fn func(name string, dst interface{})) *SecureCookie {
+	s.onUpgrade = fn
+	return s
+}
+
+// appendVersion prefixes a payload with the schema version, if it is set.
+func (s *SecureCookie) appendVersion(b []byte) []byte {
+	if s.version == 0 {
+		return b
+	}
+	out := make([]byte, 0, len(schemaMagic)+binary.MaxVarintLen64+len(b))
+	out = binary.AppendUvarint(append(out, schemaMagic...), uint64(s.version))
+	return append(out, b...)
+}
+
+// unmarshalVersion deserializes a payload prefixed with its schema version,
+// migrating it to the current version if needed. It reports whether the
+// value was migrated.
+func (s *SecureCookie) unmarshalVersion(b []byte, dst interface{}) (bool, error) {
+	var v uint64
+	if bytes.HasPrefix(b, schemaMagic) {
+		var n int
+		v, n = binary.Uvarint(b[len(schemaMagic):])
+		if n <= 0 || v < 1 {
+			return false, ErrSchemaVersion
+		}
+		b = b[len(schemaMagic)+n:]
+	}
+	if v > uint64(s.version) {
+		return false, ErrSchemaVersion
+	}
+	if int(v) == s.version {
+		return false, s.unmarshal(b, dst)
+	}
+	m, ok := s.migrations[int(v)]
+	if !ok {
+		return false, ErrSchemaVersion
+	}
+	value := reflect.New(m.typ).Interface()
+	if err := s.unmarshal(b, value); err != nil {
+		return false, err
+	}
+	for version := int(v); version < s.version; version++ {
+		var err error
+		if value, err = m.fn(value); err != nil {
+			return false, err
+		}
+		next := reflect.TypeOf(dst)
+		if version+1 < s.version {
+			if m, ok = s.migrations[version+1]; !ok {
+				return false, ErrSchemaVersion
+			}
+			next = reflect.PointerTo(m.typ)
+		}
+		if reflect.TypeOf(value) != next {
+			return false, fmt.Errorf("securecookie: migration from schema version %d returned %T, not %v", version, value, next)
+		}
+	}
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return false, fmt.Errorf("securecookie: cannot store a migrated value in %T", dst)
+	}
+	dv.Elem().Set(reflect.ValueOf(value).Elem())
+	return true, nil
+}
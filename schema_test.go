@@ -0,0 +1,207 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type userV1 struct {
+	Name string
+}
+
+type userV2 struct {
+	First, Last string
+}
+
+type userV3 struct {
+	First, Last string
+	Admin       bool
+}
+
+func migrateUserV1(v interface{}) (interface{}, error) {
+	old := v.(*userV1)
+	first, last, _ := strings.Cut(old.Name, " ")
+	return &userV2{First: first, Last: last}, nil
+}
+
+func migrateUserV2(v interface{}) (interface{}, error) {
+	old := v.(*userV2)
+	return &userV3{First: old.First, Last: old.Last}, nil
+}
+
+func TestSchemaVersion(t *testing.T) {
+	codec := func(version int) *SecureCookie {
+		return New([]byte("12345"), []byte("1234567890123456")).
+			SetSerializer(NewJSONSerializer()).
+			SchemaVersion(version).
+			Migrate(1, userV1{}, migrateUserV1).
+			Migrate(2, userV2{}, migrateUserV2)
+	}
+	v1, err := codec(1).Encode("user", &userV1{"Ada Lovelace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := codec(2).Encode("user", &userV2{"Grace", "Hopper"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, err := codec(3).Encode("user", &userV3{"Alan", "Turing", true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upgraded interface{}
+	s := codec(3).OnUpgrade(func(name string, dst interface{}) { upgraded = dst })
+	for _, test := range []struct {
+		encoded  string
+		expected userV3
+		upgraded bool
+	}{
+		{v1, userV3{"Ada", "Lovelace", false}, true},
+		{v2, userV3{"Grace", "Hopper", false}, true},
+		{v3, userV3{"Alan", "Turing", true}, false},
+	} {
+		var dst userV3
+		upgraded = nil
+		if err = s.Decode("user", test.encoded, &dst); err != nil {
+			t.Error(err)
+		}
+		if dst != test.expected {
+			t.Errorf("Expected %v, got %v.", test.expected, dst)
+		}
+		if (upgraded == &dst) != test.upgraded {
+			t.Errorf("Expected upgrade %v, got %v.", test.upgraded, upgraded != nil)
+		}
+	}
+
+	// Newer versions are rejected, as are versioned values by codecs
+	// without a version.
+	if err = codec(2).Decode("user", v3, &userV2{}); err != ErrSchemaVersion {
+		t.Errorf("Expected %v, got %v.", ErrSchemaVersion, err)
+	}
+	unversioned := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewJSONSerializer())
+	if err = unversioned.Decode("user", v3, &userV3{}); err != ErrSchemaVersion {
+		t.Errorf("Expected %v, got %v.", ErrSchemaVersion, err)
+	}
+}
+
+func TestSchemaVersionZero(t *testing.T) {
+	// Values encoded before versions were set have version 0.
+	old := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewJSONSerializer())
+	v0, err := old.Encode("user", &userV1{"Ada Lovelace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(NewJSONSerializer()).SchemaVersion(1)
+	if err = s.Decode("user", v0, &userV2{}); err != ErrSchemaVersion {
+		t.Errorf("Expected %v, got %v.", ErrSchemaVersion, err)
+	}
+	upgraded := false
+	s.Migrate(0, userV1{}, migrateUserV1).
+		OnUpgrade(func(string, interface{}) { upgraded = true })
+	var dst userV2
+	if err = s.Decode("user", v0, &dst); err != nil || !upgraded {
+		t.Errorf("Expected an upgrade, got %v (%v).", upgraded, err)
+	}
+	if expected := (userV2{"Ada", "Lovelace"}); dst != expected {
+		t.Errorf("Expected %v, got %v.", expected, dst)
+	}
+}
+
+func TestSchemaVersionGob(t *testing.T) {
+	// The version prefix is kept apart from the gob payload, which carries
+	// its type descriptors since the types are not registered.
+	old := New([]byte("12345"), []byte("1234567890123456")).SchemaVersion(1)
+	v1, err := old.Encode("user", &userV1{"Ada Lovelace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New([]byte("12345"), []byte("1234567890123456")).SchemaVersion(2).
+		Migrate(1, userV1{}, migrateUserV1)
+	v2, err := s.Encode("user", &userV2{"Grace", "Hopper"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		encoded  string
+		expected userV2
+	}{
+		{v1, userV2{"Ada", "Lovelace"}},
+		{v2, userV2{"Grace", "Hopper"}},
+	} {
+		var dst userV2
+		if err = s.Decode("user", test.encoded, &dst); err != nil {
+			t.Error(err)
+		}
+		if dst != test.expected {
+			t.Errorf("Expected %v, got %v.", test.expected, dst)
+		}
+	}
+	if err = old.Decode("user", v2, &userV1{}); err != ErrSchemaVersion {
+		t.Errorf("Expected %v, got %v.", ErrSchemaVersion, err)
+	}
+}
+
+func TestSchemaMigrationErrors(t *testing.T) {
+	old := New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(1)
+	encoded, err := old.Encode("user", &userV1{"Ada Lovelace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing migration.
+	s := New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(3).
+		Migrate(1, userV1{}, migrateUserV1)
+	if err = s.Decode("user", encoded, &userV3{}); err != ErrSchemaVersion {
+		t.Errorf("Expected %v, got %v.", ErrSchemaVersion, err)
+	}
+
+	// A migration returning the wrong type.
+	s = New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(2).
+		Migrate(1, userV1{}, func(v interface{}) (interface{}, error) { return v, nil })
+	if err = s.Decode("user", encoded, &userV2{}); err == nil {
+		t.Errorf("Expected a type error, got %v.", err)
+	}
+
+	// Errors from migrations are returned.
+	errMigration := errors.New("migration failed")
+	s = New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(2).
+		Migrate(1, userV1{}, func(interface{}) (interface{}, error) { return nil, errMigration })
+	if err = s.Decode("user", encoded, &userV2{}); err != errMigration {
+		t.Errorf("Expected %v, got %v.", errMigration, err)
+	}
+}
+
+func TestSchemaUpgradeMulti(t *testing.T) {
+	old := New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(1)
+	encoded, err := old.Encode("user", &userV1{"Ada Lovelace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded := 0
+	s := New([]byte("12345"), nil).SetSerializer(NewJSONSerializer()).SchemaVersion(2).
+		Migrate(1, userV1{}, migrateUserV1).
+		OnUpgrade(func(string, interface{}) { upgraded++ })
+	other := New([]byte("54321"), nil)
+	var dst userV2
+	if err = DecodeMulti("user", encoded, &dst, other, s); err != nil {
+		t.Error(err)
+	}
+	expected := userV2{"Ada", "Lovelace"}
+	if dst != expected {
+		t.Errorf("Expected %v, got %v.", expected, dst)
+	}
+	codec := NewTypedCodec[userV2]("user", other, s)
+	if dst, err = codec.Decode(encoded); err != nil || dst != expected {
+		t.Errorf("Expected %v, got %v (%v).", expected, dst, err)
+	}
+	if upgraded != 2 {
+		t.Errorf("Expected %v, got %v.", 2, upgraded)
+	}
+}
@@ -8,6 +8,7 @@ package securecookie
 
 import (
 	"bytes"
+	"compress/flate"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
@@ -15,13 +16,17 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"reflect"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,6 +39,10 @@ var (
 	ErrTooLong       = errors.New("securecookie: value too long")
 	ErrExpired       = errors.New("securecookie: expired")
 	ErrTooNew        = errors.New("securecookie: timestamp too new")
+
+	ErrRegistryMismatch = errors.New("securecookie: serializer registry mismatch")
+
+	ErrUnsupportedValue = errors.New("securecookie: value must be a []byte, string or Coder")
 )
 
 // Codec defines an interface to encode and decode cookie values.
@@ -49,6 +58,18 @@ type Coder interface {
 	Unmarshal([]byte) error
 }
 
+// Serializer provides an interface for providing custom serializers for
+// cookie values. Values that implement Coder bypass the serializer.
+//
+// A Serializer that also has a Register(interface{}) error method, like
+// GobSerializer, is handed the values passed to SecureCookie.Register. One
+// that also has a Fingerprint() uint32 method provides the fingerprint of
+// its registered types; see SecureCookie.Fingerprint.
+type Serializer interface {
+	Serialize(src interface{}) ([]byte, error)
+	Deserialize(src []byte, dst interface{}) error
+}
+
 // New returns a new SecureCookie.
 //
 // hashKey is required, used to authenticate values using HMAC. Create it using
@@ -65,9 +86,9 @@ func New(hashKey, blockKey []byte) *SecureCookie {
 		hashFunc:  sha256.New,
 		maxAge:    86400 * 30,
 		maxLength: 4096,
+		sz:        NewGobSerializer(),
+		detect:    DetectCoder,
 	}
-	s.enc = gob.NewEncoder(&s.buf)
-	s.dec = gob.NewDecoder(&s.buf)
 	if hashKey == nil {
 		s.err = ErrHashKeyNotSet
 	}
@@ -77,15 +98,30 @@ func New(hashKey, blockKey []byte) *SecureCookie {
 	return s
 }
 
-// Register registers a type to be used
-// with the SecureCookie's internal gob.Encoder and gob.Decoder
-// values.
+// Register registers a type to be used with the serializer of the
+// SecureCookie. For the default GobSerializer, it primes the internal
+// gob.Encoder and gob.Decoder with the type. It is a no-op for serializers
+// that do not need it.
 func (s *SecureCookie) Register(v interface{}) error {
-	bts, err := serialize(s, v)
-	if err != nil {
-		return err
+	if r, ok := s.sz.(interface {
+		Register(interface{}) error
+	}); ok {
+		return r.Register(v)
 	}
-	return deserialize(s, bts, v)
+	return nil
+}
+
+// Fingerprint returns a fingerprint of the types registered with the
+// serializer and their gob wire shapes, or 0 if the serializer does not keep
+// such a registry. Instances whose fingerprints differ may fail to decode each
+// other's cookies, so health checks can compare it across a deployment.
+func (s *SecureCookie) Fingerprint() uint32 {
+	if f, ok := s.sz.(interface {
+		Fingerprint() uint32
+	}); ok {
+		return f.Fingerprint()
+	}
+	return 0
 }
 
 // SecureCookie encodes and decodes authenticated and optionally encrypted
@@ -98,11 +134,14 @@ type SecureCookie struct {
 	maxLength int
 	maxAge    int64
 	minAge    int64
+	sz        Serializer
+	detect    int
+	embedFP   bool
+	version   int
 	err       error
-	lock      sync.Mutex
-	buf       bytes.Buffer
-	enc       *gob.Encoder
-	dec       *gob.Decoder
+	// Migrations of older schema versions, by version.
+	migrations map[int]migration
+	onUpgrade  func(name string, dst interface{})
 	// For testing purposes, the function that returns the current timestamp.
 	// If not set, it will use time.Now().UTC().Unix().
 	timeFunc func() int64
@@ -154,6 +193,38 @@ func (s *SecureCookie) BlockFunc(f func([]byte) (cipher.Block, error)) *SecureCo
 	return s
 }
 
+// SetSerializer sets the serializer used to encode and decode values.
+//
+// Default is a GobSerializer.
+func (s *SecureCookie) SetSerializer(sz Serializer) *SecureCookie {
+	s.sz = sz
+	return s
+}
+
+// Detect sets the interfaces that Encode and Decode detect on values, to
+// encode them with their own methods instead of the serializer. It is a
+// combination of DetectCoder, DetectProto, DetectBinary and DetectText;
+// see DetectCoder for the order of precedence.
+//
+// Default is DetectCoder. The other interfaces change how existing values
+// are encoded, for instance time.Time with DetectBinary, so cookies encoded
+// before they are enabled may no longer decode.
+func (s *SecureCookie) Detect(flags int) *SecureCookie {
+	s.detect = flags
+	return s
+}
+
+// EmbedFingerprint embeds the fingerprint of the serializer registry in each
+// encoded value. Decode then returns ErrRegistryMismatch for values encoded
+// by an instance whose fingerprint differs, instead of a serializer error.
+// All instances must use the same setting.
+//
+// Default is false.
+func (s *SecureCookie) EmbedFingerprint(value bool) *SecureCookie {
+	s.embedFP = value
+	return s
+}
+
 // Encode encodes a cookie value.
 //
 // It serializes, optionally encrypts, signs with a message authentication code, and
@@ -161,8 +232,8 @@ func (s *SecureCookie) BlockFunc(f func([]byte) (cipher.Block, error)) *SecureCo
 //
 // The name argument is the cookie name. It is stored with the encoded value.
 // The value argument is the value to be encoded. It can be any value that can
-// be encoded using encoding/gob. To store special structures, they must be
-// registered first using gob.Register().
+// be encoded by the serializer, encoding/gob by default. To store special
+// structures, they must be registered first using Register().
 func (s *SecureCookie) Encode(name string, value interface{}) (string, error) {
 	if s.err != nil {
 		return "", s.err
@@ -174,14 +245,15 @@ func (s *SecureCookie) Encode(name string, value interface{}) (string, error) {
 	var err error
 	var b []byte
 	// 1. Serialize.
-	if enc, ok := value.(Coder); ok {
-		b, err = enc.Marshal()
-	} else {
-		b, err = serialize(s, value)
-	}
-	if err != nil {
+	if b, err = s.marshal(value); err != nil {
 		return "", err
 	}
+	b = s.appendVersion(b)
+	if s.embedFP {
+		fp := make([]byte, 4, 4+len(b))
+		binary.BigEndian.PutUint32(fp, s.Fingerprint())
+		b = append(fp, b...)
+	}
 	// 2. Encrypt (optional).
 	if s.block != nil {
 		if b, err = encrypt(s.block, b); err != nil {
@@ -256,12 +328,8 @@ func (s *SecureCookie) Decode(name, value string, dst interface{}) error {
 	if t1, err = strconv.ParseInt(string(parts[0]), 10, 64); err != nil {
 		return ErrTimeInvalid
 	}
-	t2 := s.timestamp()
-	if s.minAge != 0 && t1 > t2-s.minAge {
-		return ErrTooNew
-	}
-	if s.maxAge != 0 && t1 < t2-s.maxAge {
-		return ErrExpired
+	if err = checkAge(t1, s.timestamp(), s.minAge, s.maxAge); err != nil {
+		return err
 	}
 	// 5. Decrypt (optional).
 	b, err = decode(parts[1])
@@ -274,10 +342,18 @@ func (s *SecureCookie) Decode(name, value string, dst interface{}) error {
 		}
 	}
 	// 6. Deserialize.
-	if dec, ok := dst.(Coder); ok {
-		err = dec.Unmarshal(b)
-	} else {
-		err = deserialize(s, b, dst)
+	if s.embedFP {
+		if len(b) < 4 {
+			return ErrRegistryMismatch
+		}
+		if binary.BigEndian.Uint32(b) != s.Fingerprint() {
+			return ErrRegistryMismatch
+		}
+		b = b[4:]
+	}
+	upgraded, err := s.unmarshalVersion(b, dst)
+	if upgraded && s.onUpgrade != nil {
+		s.onUpgrade(name, dst)
 	}
 	return err
 }
@@ -287,10 +363,28 @@ func (s *SecureCookie) Decode(name, value string, dst interface{}) error {
 // For testing purposes, the function that generates the timestamp can be
 // overridden. If not set, it will return time.Now().UTC().Unix().
 func (s *SecureCookie) timestamp() int64 {
-	if s.timeFunc == nil {
+	return now(s.timeFunc)
+}
+
+// now returns the result of timeFunc, or time.Now().UTC().Unix() if it is nil.
+func now(timeFunc func() int64) int64 {
+	if timeFunc == nil {
 		return time.Now().UTC().Unix()
 	}
-	return s.timeFunc()
+	return timeFunc()
+}
+
+// checkAge verifies that the timestamp t1 is within the range allowed by
+// minAge and maxAge, relative to the current timestamp t2. Zero values mean
+// no restriction.
+func checkAge(t1, t2, minAge, maxAge int64) error {
+	if minAge != 0 && t1 > t2-minAge {
+		return ErrTooNew
+	}
+	if maxAge != 0 && t1 < t2-maxAge {
+		return ErrExpired
+	}
+	return nil
 }
 
 // Authentication -------------------------------------------------------------
@@ -349,31 +443,281 @@ func decrypt(block cipher.Block, value []byte) ([]byte, error) {
 
 // Serialization --------------------------------------------------------------
 
-// serialize encodes a value using gob.
-func serialize(s *SecureCookie, src interface{}) ([]byte, error) {
-	s.lock.Lock()
-	s.buf.Reset()
-	err := s.enc.Encode(src)
+// GobSerializer encodes cookie values using encoding/gob.
+//
+// It reuses gob.Encoder and gob.Decoder pairs, so type information is only
+// sent once. Types must be registered with Register, in the same order, by
+// the instances that encode and decode their values. Values of other types
+// are encoded and decoded by a fresh gob.Encoder and gob.Decoder: they carry
+// their type information, which makes them longer and slower to process.
+//
+// Pairs are pooled, so that concurrent calls do not wait for each other.
+// Every pair is primed with the registered types, in registration order, so
+// that all pairs produce the same encoding.
+type GobSerializer struct {
+	pool     sync.Pool
+	registry atomic.Value // *gobRegistry
+	// lock serializes registrations.
+	lock sync.Mutex
+}
+
+// gobRegistry holds the registered values. It is replaced, not modified, by
+// registrations.
+type gobRegistry struct {
+	values []interface{} // copies of the registered values, in order
+	types  map[reflect.Type]bool
+	// fingerprint chains the wire shapes of the registered types. Gob
+	// assigns type IDs in order, so the order matters.
+	fingerprint [sha256.Size]byte
+}
+
+// gobState is a gob.Encoder and gob.Decoder pair sharing a buffer.
+type gobState struct {
+	buf bytes.Buffer
+	enc *gob.Encoder
+	dec *gob.Decoder
+	n   int // number of registered values the pair was primed with
+}
+
+// NewGobSerializer returns a new GobSerializer.
+func NewGobSerializer() *GobSerializer {
+	g := &GobSerializer{}
+	g.registry.Store(&gobRegistry{})
+	return g
+}
+
+// Serialize encodes a value using gob.
+func (g *GobSerializer) Serialize(src interface{}) ([]byte, error) {
+	reg := g.registry.Load().(*gobRegistry)
+	if !reg.types[gobBaseType(src)] {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(src); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	st, err := g.primed(reg)
 	if err != nil {
-		s.buf.Reset()
-		s.lock.Unlock()
 		return nil, err
 	}
-	out := make([]byte, len(s.buf.Bytes()))
-	copy(out, s.buf.Bytes())
-	s.lock.Unlock()
+	st.buf.Reset()
+	if err = st.enc.Encode(src); err != nil {
+		// The pair is dropped, in case the failure left it in an
+		// inconsistent state.
+		return nil, err
+	}
+	out := make([]byte, len(st.buf.Bytes()))
+	copy(out, st.buf.Bytes())
+	st.buf.Reset()
+	g.pool.Put(st)
 	return out, nil
 }
 
-// deserialize decodes a value using gob.
-func deserialize(s *SecureCookie, src []byte, dst interface{}) error {
-	s.lock.Lock()
-	s.buf.Reset()
-	s.buf.Write(src)
-	err := s.dec.Decode(dst)
-	s.buf.Reset()
-	s.lock.Unlock()
-	return err
+// Deserialize decodes a value using gob.
+func (g *GobSerializer) Deserialize(src []byte, dst interface{}) error {
+	reg := g.registry.Load().(*gobRegistry)
+	if !reg.types[gobBaseType(dst)] {
+		return gob.NewDecoder(bytes.NewReader(src)).Decode(dst)
+	}
+	st, err := g.primed(reg)
+	if err != nil {
+		return err
+	}
+	st.buf.Reset()
+	st.buf.Write(src)
+	if err = st.dec.Decode(dst); err != nil {
+		// Values that carry the type information, encoded by an instance
+		// that did not register the type, can be decoded by a fresh
+		// gob.Decoder.
+		if gob.NewDecoder(bytes.NewReader(src)).Decode(dst) == nil {
+			return nil
+		}
+		return err
+	}
+	st.buf.Reset()
+	g.pool.Put(st)
+	return nil
+}
+
+// Register primes the encoders and the decoders with the type of v, by
+// encoding and decoding it once. A copy of v is kept, to prime new pairs.
+// Registering a type again has no effect.
+func (g *GobSerializer) Register(v interface{}) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	reg := g.registry.Load().(*gobRegistry)
+	if reg.types[gobBaseType(v)] {
+		return nil
+	}
+	st, err := g.primed(reg)
+	if err != nil {
+		return err
+	}
+	cp, err := st.prime(v)
+	if err != nil {
+		return err
+	}
+	desc, _ := describeGobType(reflect.TypeOf(v))
+	next := &gobRegistry{
+		values:      append(reg.values[:len(reg.values):len(reg.values)], cp),
+		types:       make(map[reflect.Type]bool, len(reg.types)+1),
+		fingerprint: sha256.Sum256(append(reg.fingerprint[:], desc...)),
+	}
+	for k := range reg.types {
+		next.types[k] = true
+	}
+	next.types[gobBaseType(v)] = true
+	g.registry.Store(next)
+	st.n++
+	g.pool.Put(st)
+	return nil
+}
+
+// primed returns a pair from the pool, or a new one, primed with the values
+// of a registry.
+func (g *GobSerializer) primed(reg *gobRegistry) (*gobState, error) {
+	st, _ := g.pool.Get().(*gobState)
+	if st == nil {
+		st = &gobState{}
+		st.enc = gob.NewEncoder(&st.buf)
+		st.dec = gob.NewDecoder(&st.buf)
+	}
+	for ; st.n < len(reg.values); st.n++ {
+		if _, err := st.prime(reg.values[st.n]); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// prime encodes and decodes v, so that the pair has exchanged the type
+// information of its type. It returns the decoded copy.
+func (st *gobState) prime(v interface{}) (interface{}, error) {
+	st.buf.Reset()
+	defer st.buf.Reset()
+	if err := st.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	cp := reflect.New(reflect.TypeOf(v)).Interface()
+	if err := st.dec.Decode(cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// gobBaseType returns the type of v with pointers dereferenced, as gob
+// sees it.
+func gobBaseType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Fingerprint returns a fingerprint of the registered types and their wire
+// shapes, in registration order, or 0 if no type was registered.
+func (g *GobSerializer) Fingerprint() uint32 {
+	sum := g.registry.Load().(*gobRegistry).fingerprint
+	if sum == [sha256.Size]byte{} {
+		return 0
+	}
+	// 0 is reserved for serializers without registered types.
+	if fp := binary.BigEndian.Uint32(sum[:]); fp != 0 {
+		return fp
+	}
+	return 1
+}
+
+// marshalBytes returns the raw payload for codecs that carry opaque bytes.
+// The value must be a Coder, a []byte or a string.
+func marshalBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case Coder:
+		return v.Marshal()
+	case []byte:
+		return v, nil
+	case *[]byte:
+		return *v, nil
+	case string:
+		return []byte(v), nil
+	case *string:
+		return []byte(*v), nil
+	}
+	return nil, ErrUnsupportedValue
+}
+
+// unmarshalBytes stores a raw payload in dst, which must be a Coder,
+// a *[]byte or a *string.
+func unmarshalBytes(b []byte, dst interface{}) error {
+	switch d := dst.(type) {
+	case Coder:
+		return d.Unmarshal(b)
+	case *[]byte:
+		*d = append([]byte(nil), b...)
+		return nil
+	case *string:
+		*d = string(b)
+		return nil
+	}
+	return ErrUnsupportedValue
+}
+
+// marshalJSON returns the payload for codecs that carry JSON. Values that
+// implement Coder are encoded as is.
+func marshalJSON(value interface{}) ([]byte, error) {
+	if enc, ok := value.(Coder); ok {
+		return enc.Marshal()
+	}
+	return json.Marshal(value)
+}
+
+// unmarshalJSON stores a payload encoded by marshalJSON in dst.
+func unmarshalJSON(b []byte, dst interface{}) error {
+	if dec, ok := dst.(Coder); ok {
+		return dec.Unmarshal(b)
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// Compression ----------------------------------------------------------------
+
+// deflate compresses a value using DEFLATE.
+func deflate(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(value); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inflate decompresses a DEFLATE value. It returns ErrTooLong if the result
+// would exceed maxLength bytes; zero means no restriction.
+func inflate(value []byte, maxLength int) ([]byte, error) {
+	return readAll(flate.NewReader(bytes.NewReader(value)), maxLength)
+}
+
+// readAll reads r until EOF. It returns ErrTooLong if the result would
+// exceed maxLength bytes; zero means no restriction.
+func readAll(r io.Reader, maxLength int) ([]byte, error) {
+	if maxLength != 0 {
+		r = io.LimitReader(r, int64(maxLength)+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxLength != 0 && len(out) > maxLength {
+		return nil, ErrTooLong
+	}
+	return out, nil
 }
 
 // Encoding -------------------------------------------------------------------
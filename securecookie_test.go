@@ -10,9 +10,11 @@ import (
 	"crypto/aes"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -101,13 +103,13 @@ func TestSerialization(t *testing.T) {
 		deserialized map[string]string
 		err          error
 	)
-	s := New([]byte("12345"), []byte("1234567890123456"))
+	sz := NewGobSerializer()
 	for _, value := range testCookies {
-		if serialized, err = serialize(s, value); err != nil {
+		if serialized, err = sz.Serialize(value); err != nil {
 			t.Error(err)
 		} else {
 			deserialized = make(map[string]string)
-			if err = deserialize(s, serialized, &deserialized); err != nil {
+			if err = sz.Deserialize(serialized, &deserialized); err != nil {
 				t.Error(err)
 			}
 			if fmt.Sprintf("%v", deserialized) != fmt.Sprintf("%v", value) {
@@ -117,6 +119,38 @@ func TestSerialization(t *testing.T) {
 	}
 }
 
+// textSerializer stores strings as is, for testing custom serializers.
+type textSerializer struct{}
+
+func (textSerializer) Serialize(src interface{}) ([]byte, error) {
+	return []byte(src.(string)), nil
+}
+
+func (textSerializer) Deserialize(src []byte, dst interface{}) error {
+	*dst.(*string) = string(src)
+	return nil
+}
+
+func TestCustomSerializer(t *testing.T) {
+	s := New([]byte("12345"), nil).SetSerializer(textSerializer{})
+	if err := s.Register(&FooBar{}); err != nil {
+		t.Errorf("Expected no error registering with a serializer without Register, got %v.", err)
+	}
+	encoded, err := s.Encode("sid", "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := base64.URLEncoding.DecodeString(encoded)
+	parts, _ := pipesplit(b)
+	if raw, _ := decode(parts[1]); string(raw) != "plain" {
+		t.Errorf("Expected %v, got %v.", "plain", string(raw))
+	}
+	var dst string
+	if err = s.Decode("sid", encoded, &dst); err != nil || dst != "plain" {
+		t.Errorf("Unexpected value %v (%v)", dst, err)
+	}
+}
+
 func TestFmtMac(t *testing.T) {
 	tests := []struct {
 		Name string
@@ -252,6 +286,55 @@ func TestBackwardsCompatibility(t *testing.T) {
 	}
 }
 
+func TestFingerprint(t *testing.T) {
+	one := New([]byte("12345"), []byte("1234567890123456")).EmbedFingerprint(true)
+	two := New([]byte("12345"), []byte("1234567890123456")).EmbedFingerprint(true)
+	if fp := one.Fingerprint(); fp != 0 {
+		t.Errorf("Expected %v, got %v.", 0, fp)
+	}
+	one.Register(&FooBar{})
+	one.Register(&gobTree{})
+	two.Register(&gobTree{})
+	two.Register(&FooBar{})
+	if one.Fingerprint() == two.Fingerprint() {
+		t.Errorf("Expected different fingerprints, got %v.", one.Fingerprint())
+	}
+
+	encoded, err := one.Encode("sid", &FooBar{42, "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = two.Decode("sid", encoded, &FooBar{}); err != ErrRegistryMismatch {
+		t.Errorf("Expected %v, got %v.", ErrRegistryMismatch, err)
+	}
+
+	// The same types registered in the same order match.
+	three := New([]byte("12345"), []byte("1234567890123456")).EmbedFingerprint(true)
+	three.Register(&FooBar{})
+	three.Register(&gobTree{})
+	if one.Fingerprint() != three.Fingerprint() {
+		t.Errorf("Expected %v, got %v.", one.Fingerprint(), three.Fingerprint())
+	}
+	dst := &FooBar{}
+	if err = three.Decode("sid", encoded, dst); err != nil || dst.Foo != 42 {
+		t.Errorf("Unexpected value %+v (%v)", dst, err)
+	}
+
+	// Registering a type again, even as a value, changes nothing.
+	three.Register(&FooBar{})
+	three.Register(gobTree{})
+	if one.Fingerprint() != three.Fingerprint() {
+		t.Errorf("Expected %v, got %v.", one.Fingerprint(), three.Fingerprint())
+	}
+
+	// Serializers without a registry have no fingerprint.
+	four := New([]byte("12345"), nil).SetSerializer(NewJSONSerializer())
+	four.Register(&FooBar{})
+	if fp := four.Fingerprint(); fp != 0 {
+		t.Errorf("Expected %v, got %v.", 0, fp)
+	}
+}
+
 func TestDifferentCookies(t *testing.T) {
 	one := New([]byte("12345"), []byte("1234567890123456"))
 	two := New([]byte("12345"), []byte("1234567890123456"))
@@ -355,3 +438,137 @@ func BenchmarkRoundtripOverride(b *testing.B) {
 		}
 	}
 }
+
+func TestGobSerializerConcurrency(t *testing.T) {
+	one := New([]byte("12345"), []byte("1234567890123456"))
+	two := New([]byte("12345"), []byte("1234567890123456"))
+	one.Register(&FooBar{})
+	two.Register(&FooBar{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				src := &FooBar{i*100 + j, "bar"}
+				encoded, err := one.Encode("sid", src)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// Types registered after the first values were encoded.
+				if j == 50 {
+					one.Register(&gobTree{})
+					two.Register(&gobTree{})
+				}
+				dst := &FooBar{}
+				if err = two.Decode("sid", encoded, dst); err != nil || *dst != *src {
+					t.Errorf("Expected %v, got %v (%v).", src, dst, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	// Each goroutine registered gobTree, which only counts once.
+	three := New([]byte("12345"), []byte("1234567890123456"))
+	three.Register(&FooBar{})
+	three.Register(&gobTree{})
+	for _, s := range []*SecureCookie{one, two} {
+		if s.Fingerprint() != three.Fingerprint() {
+			t.Errorf("Expected %v, got %v.", three.Fingerprint(), s.Fingerprint())
+		}
+	}
+}
+
+func TestGobSerializerUnregistered(t *testing.T) {
+	one := New([]byte("12345"), []byte("1234567890123456")).EmbedFingerprint(true)
+	two := New([]byte("12345"), []byte("1234567890123456")).EmbedFingerprint(true)
+	one.Register(&FooBar{})
+	two.Register(&FooBar{})
+	fingerprint := one.Fingerprint()
+
+	// Values of unregistered types carry their type descriptors, so every
+	// value decodes on its own, in any order and on any instance.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				src := &gobTree{Name: "root", Children: []*gobTree{{Name: "leaf"}}}
+				encoded, err := one.Encode("sid", src)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				dst := &gobTree{}
+				if err = two.Decode("sid", encoded, dst); err != nil || !reflect.DeepEqual(dst, src) {
+					t.Errorf("Expected %v, got %v (%v).", src, dst, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := one.Fingerprint(); got != fingerprint {
+		t.Errorf("Expected %v, got %v.", fingerprint, got)
+	}
+}
+
+// lockedSerializer serializes all calls through one mutex, like the
+// GobSerializer did when it had a single gob.Encoder and gob.Decoder.
+type lockedSerializer struct {
+	sync.Mutex
+	sz *GobSerializer
+}
+
+func (l *lockedSerializer) Register(v interface{}) error {
+	l.Lock()
+	defer l.Unlock()
+	return l.sz.Register(v)
+}
+
+func (l *lockedSerializer) Serialize(src interface{}) ([]byte, error) {
+	l.Lock()
+	defer l.Unlock()
+	return l.sz.Serialize(src)
+}
+
+func (l *lockedSerializer) Deserialize(src []byte, dst interface{}) error {
+	l.Lock()
+	defer l.Unlock()
+	return l.sz.Deserialize(src, dst)
+}
+
+func benchmarkRoundtripParallel(b *testing.B, sz Serializer) {
+	cook := New([]byte("12345"), []byte("1234567890123456")).SetSerializer(sz)
+	cook.Register(&FooBar{})
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		src := &FooBar{42, "bar"}
+		for pb.Next() {
+			val, err := cook.Encode("sid", src)
+			if err != nil {
+				// Fatal must not be called outside the benchmark goroutine.
+				b.Error(err)
+				return
+			}
+			if err = cook.Decode("sid", val, src); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkRoundtripParallel(b *testing.B) {
+	benchmarkRoundtripParallel(b, NewGobSerializer())
+}
+
+func BenchmarkRoundtripParallelLocked(b *testing.B) {
+	benchmarkRoundtripParallel(b, &lockedSerializer{sz: NewGobSerializer()})
+}
@@ -0,0 +1,278 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+var (
+	ErrTruncated    = errors.New("securecookie: stream is truncated")
+	ErrStreamHeader = errors.New("securecookie: invalid stream header")
+
+	errStreamClosed = errors.New("securecookie: write to closed stream")
+)
+
+const (
+	// streamChunkSize is the length of the chunks written by seal writers.
+	streamChunkSize = 64 << 10
+	// streamMaxChunkSize is the maximum chunk length accepted by open
+	// readers, which hold a chunk in memory.
+	streamMaxChunkSize = 16 << 20
+)
+
+// streamMagic starts sealed streams, and holds their format version.
+var streamMagic = []byte("scs\x01")
+
+// NewSealWriter returns a writer that authenticates, and encrypts if a block
+// key is set, the data written to w, to be read with NewOpenReader.
+//
+// The data is split in chunks that are authenticated separately, with the
+// STREAM construction: the MAC of each chunk covers the cookie name, the
+// stream header, the position of the chunk and whether it is the last one,
+// so that chunks cannot be reordered, moved across streams or dropped. As
+// with Encode, the stream is bound to the name and to the current timestamp,
+// which NewOpenReader checks against MinAge and MaxAge.
+//
+// Close must be called to write the last chunk; it does not close w.
+func (s *SecureCookie) NewSealWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.hashKey == nil {
+		s.err = ErrHashKeyNotSet
+		return nil, s.err
+	}
+	nonceSize := 16
+	if s.block != nil {
+		nonceSize = s.block.BlockSize()
+	}
+	nonce := GenerateRandomKey(nonceSize)
+	if nonce == nil {
+		return nil, errors.New("securecookie: failed to generate random nonce")
+	}
+	header := make([]byte, 0, len(streamMagic)+12+nonceSize)
+	header = append(header, streamMagic...)
+	header = binary.BigEndian.AppendUint64(header, uint64(s.timestamp()))
+	header = binary.BigEndian.AppendUint32(header, streamChunkSize)
+	header = append(header, nonce...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	sw := &sealWriter{
+		w:      w,
+		mac:    hmac.New(s.hashFunc, s.hashKey),
+		prefix: append([]byte(name+"|"), header...),
+	}
+	// The buffer has room for the MAC, appended to the chunk.
+	sw.buf = make([]byte, 0, streamChunkSize+sw.mac.Size())
+	if s.block != nil {
+		sw.stream = cipher.NewCTR(s.block, nonce)
+	}
+	return sw, nil
+}
+
+// NewOpenReader returns a reader of the data sealed by NewSealWriter in r.
+//
+// It reads the stream header and the first chunk, and returns an error if
+// they are not authentic or if the timestamp is outside the range allowed by
+// MinAge and MaxAge. Reads return ErrMacInvalid for chunks that are not
+// authentic, and ErrTruncated if the stream ends before its last chunk; the
+// data of a chunk is only returned once it is authenticated.
+func (s *SecureCookie) NewOpenReader(r io.Reader, name string) (io.Reader, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.hashKey == nil {
+		s.err = ErrHashKeyNotSet
+		return nil, s.err
+	}
+	nonceSize := 16
+	if s.block != nil {
+		nonceSize = s.block.BlockSize()
+	}
+	header := make([]byte, len(streamMagic)+12+nonceSize)
+	if _, err := io.ReadFull(r, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrTruncated
+	} else if err != nil {
+		return nil, err
+	}
+	if string(header[:len(streamMagic)]) != string(streamMagic) {
+		return nil, ErrStreamHeader
+	}
+	timestamp := int64(binary.BigEndian.Uint64(header[len(streamMagic):]))
+	chunkSize := binary.BigEndian.Uint32(header[len(streamMagic)+8:])
+	if chunkSize == 0 || chunkSize > streamMaxChunkSize {
+		return nil, ErrStreamHeader
+	}
+	or := &openReader{
+		r:      bufio.NewReader(r),
+		mac:    hmac.New(s.hashFunc, s.hashKey),
+		prefix: append([]byte(name+"|"), header...),
+	}
+	or.record = make([]byte, int(chunkSize)+or.mac.Size())
+	if s.block != nil {
+		or.stream = cipher.NewCTR(s.block, header[len(header)-nonceSize:])
+	}
+	// The first chunk authenticates the header, timestamp included.
+	if err := or.next(); err != nil {
+		return nil, err
+	}
+	if err := checkAge(timestamp, s.timestamp(), s.minAge, s.maxAge); err != nil {
+		return nil, err
+	}
+	return or, nil
+}
+
+// writeChunkMac writes to h the data that the MAC of a chunk covers, except
+// the chunk itself.
+func writeChunkMac(h hash.Hash, prefix []byte, index uint64, final bool) {
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[:], index)
+	if final {
+		b[8] = 1
+	}
+	h.Reset()
+	h.Write(prefix)
+	h.Write(b[:])
+}
+
+// sealWriter writes sealed chunks.
+type sealWriter struct {
+	w      io.Writer
+	mac    hash.Hash
+	prefix []byte // name and header, covered by the MAC of each chunk
+	stream cipher.Stream
+	buf    []byte
+	index  uint64
+	err    error
+}
+
+// Write buffers p, and writes the chunks that are filled.
+func (sw *sealWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if sw.err != nil {
+			return n, sw.err
+		}
+		// Full chunks are only written when more data follows, since the
+		// last one is marked as such.
+		if len(sw.buf) == streamChunkSize {
+			sw.err = sw.flush(false)
+			continue
+		}
+		k := copy(sw.buf[len(sw.buf):streamChunkSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, sw.err
+}
+
+// Close writes the last chunk.
+func (sw *sealWriter) Close() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if sw.err = sw.flush(true); sw.err != nil {
+		return sw.err
+	}
+	sw.err = errStreamClosed
+	return nil
+}
+
+// flush encrypts and writes the buffered chunk, followed by its MAC.
+func (sw *sealWriter) flush(final bool) error {
+	if sw.stream != nil {
+		sw.stream.XORKeyStream(sw.buf, sw.buf)
+	}
+	writeChunkMac(sw.mac, sw.prefix, sw.index, final)
+	mac := createMac(sw.mac, sw.buf)
+	if _, err := sw.w.Write(append(sw.buf, mac...)); err != nil {
+		return err
+	}
+	sw.index++
+	sw.buf = sw.buf[:0]
+	return nil
+}
+
+// openReader reads sealed chunks.
+type openReader struct {
+	r      *bufio.Reader
+	mac    hash.Hash
+	prefix []byte // name and header, covered by the MAC of each chunk
+	stream cipher.Stream
+	record []byte // chunk and MAC
+	plain  []byte // unread data of the current chunk
+	index  uint64
+	final  bool
+	err    error
+}
+
+// Read returns the data of authenticated chunks.
+func (or *openReader) Read(p []byte) (int, error) {
+	for len(or.plain) == 0 {
+		if or.err != nil {
+			return 0, or.err
+		}
+		or.err = or.next()
+	}
+	n := copy(p, or.plain)
+	or.plain = or.plain[n:]
+	return n, nil
+}
+
+// next reads, authenticates and decrypts the next chunk.
+func (or *openReader) next() error {
+	if or.final {
+		return io.EOF
+	}
+	n, err := io.ReadFull(or.r, or.record)
+	switch err {
+	case nil:
+		// A full chunk is the last one if nothing follows it.
+		if _, err = or.r.Peek(1); err == io.EOF {
+			or.final = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF:
+		or.final = true
+	case io.EOF:
+		return ErrTruncated
+	default:
+		return err
+	}
+	size := or.mac.Size()
+	if n < size {
+		return ErrMacInvalid
+	}
+	chunk, mac := or.record[:n-size], or.record[n-size:n]
+	writeChunkMac(or.mac, or.prefix, or.index, or.final)
+	if err = verifyMac(or.mac, chunk, mac); err != nil {
+		if or.final {
+			// A chunk that is authentic but not marked as the last one
+			// means that the following ones were dropped.
+			writeChunkMac(or.mac, or.prefix, or.index, false)
+			if verifyMac(or.mac, chunk, mac) == nil {
+				return ErrTruncated
+			}
+		}
+		return err
+	}
+	if or.stream != nil {
+		or.stream.XORKeyStream(chunk, chunk)
+	}
+	or.plain = chunk
+	or.index++
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// seal returns data sealed by s, written in small pieces.
+func seal(t *testing.T, s *SecureCookie, name string, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := s.NewSealWriter(&buf, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.CopyBuffer(w, bytes.NewReader(data), make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// open returns the data sealed in b, or the first error.
+func open(s *SecureCookie, name string, b []byte) ([]byte, error) {
+	r, err := s.NewOpenReader(bytes.NewReader(b), name)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	for _, s := range []*SecureCookie{
+		New([]byte("12345"), nil),
+		New([]byte("12345"), []byte("1234567890123456")),
+	} {
+		for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3 * streamChunkSize} {
+			data := make([]byte, size)
+			rand.Read(data)
+			sealed := seal(t, s, "report", data)
+			// Shorter data may appear in the sealed stream by chance.
+			if s.block != nil && size >= 16 && bytes.Contains(sealed, data) {
+				t.Errorf("Data of size %d is not encrypted.", size)
+			}
+			opened, err := open(s, "report", sealed)
+			if err != nil {
+				t.Fatalf("Size %d: %v", size, err)
+			}
+			if !bytes.Equal(opened, data) {
+				t.Errorf("Size %d: data does not round-trip.", size)
+			}
+		}
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	s := New([]byte("12345"), []byte("1234567890123456"))
+	data := make([]byte, 3*streamChunkSize)
+	sealed := seal(t, s, "report", data)
+	header := len(streamMagic) + 12 + 16
+	record := streamChunkSize + 32
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[header+record+10] ^= 1
+	swapped := append([]byte(nil), sealed[:header]...)
+	swapped = append(swapped, sealed[header+record:header+2*record]...)
+	swapped = append(swapped, sealed[header:header+record]...)
+	swapped = append(swapped, sealed[header+2*record:]...)
+
+	for _, test := range []struct {
+		name   string
+		sealed []byte
+		err    error
+	}{
+		{"report", sealed[:header-1], ErrTruncated},
+		{"report", sealed[:header], ErrTruncated},
+		{"report", sealed[:header+2*record], ErrTruncated},
+		{"report", sealed[:header+2*record-1], ErrMacInvalid},
+		{"report", tampered, ErrMacInvalid},
+		{"report", swapped, ErrMacInvalid},
+		{"upload", sealed, ErrMacInvalid},
+		{"report", append([]byte("xxxx"), sealed[4:]...), ErrStreamHeader},
+		{"report", append(append([]byte(nil), sealed...), 0), ErrMacInvalid},
+	} {
+		if _, err := open(s, test.name, test.sealed); err != test.err {
+			t.Errorf("Expected %v, got %v.", test.err, err)
+		}
+	}
+
+	// Timestamps are checked as with Decode.
+	old := New([]byte("12345"), []byte("1234567890123456"))
+	old.timeFunc = func() int64 { return 1600000000 }
+	if _, err := open(s, "report", seal(t, old, "report", data)); err != ErrExpired {
+		t.Errorf("Expected %v, got %v.", ErrExpired, err)
+	}
+}
+
+func TestStreamAge(t *testing.T) {
+	var ts int64 = 1600000000
+	s := New([]byte("12345"), []byte("1234567890123456")).MaxAge(60).MinAge(10)
+	s.timeFunc = func() int64 { return ts }
+	sealed := seal(t, s, "report", []byte("data"))
+	for _, test := range []struct {
+		age int64
+		err error
+	}{
+		{9, ErrTooNew},
+		{10, nil},
+		{60, nil},
+		{61, ErrExpired},
+	} {
+		s.timeFunc = func() int64 { return ts + test.age }
+		if _, err := open(s, "report", sealed); err != test.err {
+			t.Errorf("Age %d: expected %v, got %v.", test.age, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"reflect"
+)
+
+// TypedCodec encodes and decodes values of type T in the cookie with a given
+// name, so that the type of values is checked at compile time.
+//
+// Values are passed to the codecs by pointer, so that Coder and the other
+// interfaces detected by SecureCookie are found on types that implement them
+// with pointer receivers. If T is itself a pointer type, the pointer is passed
+// as is, and Decode returns a newly allocated value.
+type TypedCodec[T any] struct {
+	name   string
+	codecs []Codec
+	elem   reflect.Type // element type if T is a pointer type
+	err    error
+}
+
+// NewTypedCodec returns a new TypedCodec for the cookie with the given name.
+//
+// The codecs are tried in order, as with EncodeMulti and DecodeMulti. Codecs
+// that have a Register(interface{}) error method, like SecureCookie, are
+// registered with T.
+func NewTypedCodec[T any](name string, codecs ...Codec) *TypedCodec[T] {
+	c := &TypedCodec[T]{name: name, codecs: codecs}
+	if len(codecs) == 0 {
+		c.err = ErrNoCodecs
+		return c
+	}
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt.Kind() == reflect.Ptr {
+		c.elem = rt.Elem()
+		rt = c.elem
+	}
+	if rt.Kind() == reflect.Interface {
+		// There is no value to register for interface types.
+		return c
+	}
+	for _, codec := range codecs {
+		if r, ok := codec.(interface {
+			Register(interface{}) error
+		}); ok {
+			if err := r.Register(reflect.New(rt).Interface()); err != nil {
+				c.err = err
+				return c
+			}
+		}
+	}
+	return c
+}
+
+// Name returns the name of the cookie.
+func (c *TypedCodec[T]) Name() string {
+	return c.name
+}
+
+// Encode encodes a cookie value with the first codec that succeeds.
+func (c *TypedCodec[T]) Encode(value T) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	var src interface{} = &value
+	if c.elem != nil {
+		src = value
+	}
+	return EncodeMulti(c.name, src, c.codecs...)
+}
+
+// Decode decodes a cookie value with the first codec that succeeds. On
+// failure, it returns the zero value of T.
+func (c *TypedCodec[T]) Decode(value string) (T, error) {
+	var dst, zero T
+	if c.err != nil {
+		return zero, c.err
+	}
+	var target interface{} = &dst
+	if c.elem != nil {
+		target = reflect.New(c.elem).Interface()
+		dst = target.(T)
+	}
+	if err := DecodeMulti(c.name, value, target, c.codecs...); err != nil {
+		return zero, err
+	}
+	return dst, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 The Gorilla Authors.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securecookie
+
+import (
+	"testing"
+)
+
+func TestTypedCodec(t *testing.T) {
+	old := New([]byte("12345"), []byte("1234567890123456"))
+	cur := New([]byte("54321"), []byte("6543210987654321"))
+	encoder := NewTypedCodec[FooBar]("sid", old)
+	decoder := NewTypedCodec[FooBar]("sid", cur, old)
+	if name := decoder.Name(); name != "sid" {
+		t.Errorf("Expected %v, got %v.", "sid", name)
+	}
+
+	src := FooBar{42, "bar"}
+	encoded, err := encoder.Encode(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := decoder.Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst != src {
+		t.Errorf("Expected %v, got %v.", src, dst)
+	}
+
+	// Failures return the zero value.
+	other := NewTypedCodec[FooBar]("other", cur, old)
+	if dst, err = other.Decode(encoded); err == nil || dst != (FooBar{}) {
+		t.Errorf("Expected an error and %v, got %v (%v).", FooBar{}, dst, err)
+	}
+	if _, err = NewTypedCodec[FooBar]("sid").Encode(src); err != ErrNoCodecs {
+		t.Errorf("Expected %v, got %v.", ErrNoCodecs, err)
+	}
+}
+
+func TestTypedCodecPointers(t *testing.T) {
+	s := New([]byte("12345"), nil)
+	ptr := NewTypedCodec[*FooBar]("sid", s)
+	encoded, err := ptr.Encode(&FooBar{42, "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := ptr.Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst == nil || *dst != (FooBar{42, "bar"}) {
+		t.Errorf("Expected %v, got %v.", &FooBar{42, "bar"}, dst)
+	}
+
+	// Coder has pointer receivers, but values are passed by pointer.
+	coder := NewTypedCodec[TestCoder]("sid", s)
+	if encoded, err = coder.Encode(TestCoder{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	raw := &TestCoder{}
+	if err = s.Decode("sid", encoded, raw); err != nil || raw.Str != "foo" {
+		t.Errorf("Expected %v, got %v (%v).", "foo", raw.Str, err)
+	}
+	value, err := coder.Decode(encoded)
+	if err != nil || value.Str != "foo" {
+		t.Errorf("Expected %v, got %v (%v).", "foo", value.Str, err)
+	}
+}